@@ -0,0 +1,174 @@
+// Package cache implements an in-memory LRU response cache and HTTP
+// conditional request handling.
+package cache
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy enables caching for a route
+type Policy struct {
+	// TTL is how long a response is served from the cache
+	TTL time.Duration
+	// Vary are the request headers that select different responses, like
+	// Accept on a route answering with HTML or JSON
+	Vary []string
+}
+
+// Enabled reports whether responses are cached at all
+func (p Policy) Enabled() bool {
+	return p.TTL > 0
+}
+
+// Key builds the cache key of a request to route. The query is sorted, so
+// that the order of the parameters doesn't matter.
+func (p Policy) Key(route string, u *url.URL, h http.Header) string {
+	var b strings.Builder
+	b.WriteString(RoutePrefix(route))
+	b.WriteString(u.EscapedPath())
+	if q := u.Query(); len(q) > 0 {
+		b.WriteByte('?')
+		b.WriteString(q.Encode())
+	}
+	for _, name := range p.Vary {
+		b.WriteByte(0)
+		b.WriteString(http.CanonicalHeaderKey(name))
+		b.WriteByte('=')
+		b.WriteString(strings.Join(h.Values(name), ","))
+	}
+	return b.String()
+}
+
+// Entry is a cached response
+type Entry struct {
+	Status       int
+	Header       http.Header
+	Body         []byte
+	ETag         string
+	LastModified time.Time
+	Expires      time.Time
+}
+
+// size approximates the memory an entry holds
+func (e Entry) size(key string) int64 {
+	n := len(key) + len(e.Body) + len(e.ETag)
+	for k, v := range e.Header {
+		n += len(k)
+		for _, s := range v {
+			n += len(s)
+		}
+	}
+	return int64(n)
+}
+
+// LRU is a cache bounded by the size of its entries, the least recently used
+// entries are evicted first
+type LRU struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	order    *list.List
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type item struct {
+	key   string
+	entry Entry
+	size  int64
+}
+
+// New creates an empty cache holding at most maxBytes of responses
+func New(maxBytes int64) *LRU {
+	return &LRU{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		items:    map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+// Get returns the entry for key unless it is missing or expired
+func (l *LRU) Get(key string) (Entry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, ok := l.items[key]
+	if !ok {
+		return Entry{}, false
+	}
+	it := el.Value.(*item)
+	if !l.now().Before(it.entry.Expires) {
+		l.remove(el)
+		return Entry{}, false
+	}
+	l.order.MoveToFront(el)
+	return it.entry, true
+}
+
+// Set stores an entry, evicting the least recently used ones to make room.
+// Entries larger than the whole cache are not stored.
+func (l *LRU) Set(key string, e Entry) {
+	size := e.size(key)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, ok := l.items[key]; ok {
+		l.remove(el)
+	}
+	if size > l.maxBytes {
+		return
+	}
+	for l.size+size > l.maxBytes {
+		l.remove(l.order.Back())
+	}
+	l.items[key] = l.order.PushFront(&item{key: key, entry: e, size: size})
+	l.size += size
+}
+
+// Purge removes the entries whose key starts with prefix, all of them when
+// prefix is empty, and returns how many were removed
+func (l *LRU) Purge(prefix string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for key, el := range l.items {
+		if strings.HasPrefix(key, prefix) {
+			l.remove(el)
+			n++
+		}
+	}
+	return n
+}
+
+// Len returns the number of entries
+func (l *LRU) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.items)
+}
+
+// Size returns the approximate number of bytes held
+func (l *LRU) Size() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size
+}
+
+func (l *LRU) remove(el *list.Element) {
+	it := l.order.Remove(el).(*item)
+	delete(l.items, it.key)
+	l.size -= it.size
+}
+
+// RoutePrefix is the prefix shared by the keys of route, for Purge
+func RoutePrefix(route string) string {
+	return route + "\x00"
+}
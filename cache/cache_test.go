@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestPolicyKey(t *testing.T) {
+	p := Policy{TTL: time.Minute, Vary: []string{"accept"}}
+	a, _ := url.Parse("/logs?b=2&a=1")
+	b, _ := url.Parse("/logs?a=1&b=2")
+	json := http.Header{"Accept": {"application/json"}}
+	html := http.Header{"Accept": {"text/html"}}
+
+	if p.Key("GetLogs", a, json) != p.Key("GetLogs", b, json) {
+		t.Errorf("Expected the query order not to matter")
+	}
+	if p.Key("GetLogs", a, json) == p.Key("GetLogs", a, html) {
+		t.Errorf("Expected the Accept header to select the entry")
+	}
+	if p.Key("GetLogs", a, json) == p.Key("Other", a, json) {
+		t.Errorf("Expected routes to have their own entries")
+	}
+	if (Policy{TTL: time.Minute}).Key("GetLogs", a, json) != (Policy{TTL: time.Minute}).Key("GetLogs", a, html) {
+		t.Errorf("Expected headers not listed in Vary to be ignored")
+	}
+}
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(20)
+	l.now = func() time.Time { return now }
+	entry := func(body string) Entry {
+		return Entry{Status: 200, Body: []byte(body), Expires: now.Add(time.Minute)}
+	}
+
+	l.Set("a", entry("aaaaaaaa"))
+	l.Set("b", entry("bbbbbbbb"))
+	if _, ok := l.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	// a was used last, so b is evicted to make room
+	l.Set("c", entry("cccccccc"))
+	if _, ok := l.Get("b"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if _, ok := l.Get("a"); !ok {
+		t.Errorf("Expected a to be kept")
+	}
+	if l.Size() != 18 {
+		t.Errorf("Expected 18 bytes, got %d", l.Size())
+	}
+
+	l.Set("big", entry("this body is larger than the cache"))
+	if _, ok := l.Get("big"); ok || l.Len() != 2 {
+		t.Errorf("Expected entries larger than the cache to be skipped")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := l.Get("a"); ok {
+		t.Errorf("Expected a to expire")
+	}
+
+	l.Set("route1\x00/x", Entry{Expires: now.Add(time.Minute)})
+	l.Set("route2\x00/x", Entry{Expires: now.Add(time.Minute)})
+	if n := l.Purge("route1\x00"); n != 1 {
+		t.Errorf("Expected 1 entry to be purged, got %d", n)
+	}
+	l.Purge("")
+	if l.Len() != 0 || l.Size() != 0 {
+		t.Errorf("Expected everything to be purged, %d entries and %d bytes left", l.Len(), l.Size())
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC)
+	etag := ETag([]byte("body"))
+
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		want   bool
+	}{
+		{"no conditions", "GET", http.Header{}, false},
+		{"matching etag", "GET", http.Header{"If-None-Match": {`"other", ` + etag}}, true},
+		{"weak etag", "GET", http.Header{"If-None-Match": {"W/" + etag}}, true},
+		{"any etag", "HEAD", http.Header{"If-None-Match": {"*"}}, true},
+		{"other etag wins over date", "GET", http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified.Format(http.TimeFormat)}}, false},
+		{"not modified since", "GET", http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}, true},
+		{"modified since", "GET", http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}, false},
+		{"not a read", "POST", http.Header{"If-None-Match": {etag}}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Header = tt.header
+		if got := NotModified(r, etag, modified); got != tt.want {
+			t.Errorf("%s: NotModified = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package compression negotiates and applies gzip and brotli response compression.
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	Gzip   = "gzip"
+	Brotli = "br"
+)
+
+// DefaultContentTypes are the media types worth compressing, a trailing
+// "/*" matches a whole type
+var DefaultContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"application/problem+json",
+	"image/svg+xml",
+}
+
+// Config decides which responses are compressed
+type Config struct {
+	// MinSize is the smallest body worth compressing, in bytes
+	MinSize int
+	// ContentTypes lists the compressible media types
+	ContentTypes []string
+}
+
+// Compressible reports whether responses of contentType should be compressed
+func (c Config) Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.ContentTypes {
+		if t == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate picks the best of the offered encodings the client accepts,
+// brotli before gzip when the client likes them equally. It returns an empty
+// string when the response should not be encoded.
+func Negotiate(acceptEncoding string, offered ...string) string {
+	if len(offered) == 0 {
+		offered = []string{Brotli, Gzip}
+	}
+
+	weights := map[string]float64{}
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range offered {
+		q, ok := weights[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+var (
+	gzipPool   sync.Pool
+	brotliPool sync.Pool
+)
+
+// newEncoder returns a pooled encoder writing to w, release returns it to the pool once closed
+func newEncoder(encoding string, w io.Writer) (enc io.WriteCloser, release func()) {
+	switch encoding {
+	case Gzip:
+		gz, _ := gzipPool.Get().(*gzip.Writer)
+		if gz == nil {
+			gz = gzip.NewWriter(w)
+		} else {
+			gz.Reset(w)
+		}
+		return gz, func() { gzipPool.Put(gz) }
+	case Brotli:
+		br, _ := brotliPool.Get().(*brotli.Writer)
+		if br == nil {
+			// Level 4 is a good trade-off for compressing on the fly
+			br = brotli.NewWriterLevel(w, 4)
+		} else {
+			br.Reset(w)
+		}
+		return br, func() { brotliPool.Put(br) }
+	}
+	return nil, nil
+}
+
+// Writer compresses a response once it is known to be worth it. The body is
+// buffered until it reaches MinSize or is flushed, so small responses and
+// responses of other content types pass through unchanged.
+type Writer struct {
+	w        http.ResponseWriter
+	cfg      Config
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool
+	enc     io.WriteCloser
+	release func()
+}
+
+// NewWriter wraps w to compress with encoding, as returned by Negotiate
+func NewWriter(w http.ResponseWriter, encoding string, cfg Config) *Writer {
+	return &Writer{w: w, cfg: cfg, encoding: encoding}
+}
+
+// Header returns the response headers, they can be changed until the first flush
+func (w *Writer) Header() http.Header {
+	return w.w.Header()
+}
+
+// WriteHeader records the status, it is sent with the first flush
+func (w *Writer) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+// Status returns the response status
+func (w *Writer) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether a body or header has been written
+func (w *Writer) Written() bool {
+	return w.decided || len(w.buf) > 0
+}
+
+// Write buffers or compresses b
+func (w *Writer) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.cfg.MinSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(b)
+	}
+	return w.w.Write(b)
+}
+
+// Flush sends what has been written so far, for streaming responses
+func (w *Writer) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// WriteHeaderNow sends the headers without a body
+func (w *Writer) WriteHeaderNow() {
+	if !w.decided {
+		w.decide(false)
+	}
+}
+
+// Close finishes the response, it must be called once the handler is done
+func (w *Writer) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.cfg.MinSize); err != nil {
+			return err
+		}
+	}
+	if w.enc == nil {
+		return nil
+	}
+	err := w.enc.Close()
+	w.release()
+	w.enc = nil
+	return err
+}
+
+// decide sends the headers, compressed when allowed and worth it, and the buffered body
+func (w *Writer) decide(worthIt bool) error {
+	w.decided = true
+	h := w.w.Header()
+
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	compressible := w.cfg.Compressible(h.Get("Content-Type"))
+	if compressible {
+		h.Add("Vary", "Accept-Encoding")
+	}
+
+	status := w.Status()
+	if worthIt && compressible && len(w.buf) > 0 &&
+		h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		status >= 200 && status != http.StatusNoContent && status != http.StatusPartialContent && status != http.StatusNotModified {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		w.enc, w.release = newEncoder(w.encoding, w.w)
+	}
+
+	w.w.WriteHeader(status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.w.Write(buf)
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"gzip, deflate, br", Brotli},
+		{"gzip", Gzip},
+		{"br;q=0.5, gzip", Gzip},
+		{"gzip;q=0, br;q=0", ""},
+		{"*", Brotli},
+		{"*;q=0.1, gzip;q=0.5", Gzip},
+		{"identity", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+	if got := Negotiate("br, gzip", Gzip); got != Gzip {
+		t.Errorf("Expected only offered encodings to be picked, got %q", got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	cfg := Config{MinSize: 100, ContentTypes: DefaultContentTypes}
+	large := strings.Repeat(`{"status":"ok"}`, 20)
+
+	write := func(encoding, contentType, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		w := NewWriter(rec, encoding, cfg)
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, body)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v", err)
+		}
+		return rec
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		rec := write(Gzip, "application/json; charset=utf-8", large)
+		if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != Gzip {
+			t.Fatalf("Expected a gzipped 201, got %d %q", rec.Code, rec.Header().Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("Invalid gzip body: %v", err)
+		}
+		if body, _ := io.ReadAll(zr); string(body) != large {
+			t.Errorf("Body changed by compression")
+		}
+	})
+
+	t.Run("Brotli", func(t *testing.T) {
+		rec := write(Brotli, "text/plain", large)
+		if rec.Header().Get("Content-Encoding") != Brotli {
+			t.Fatalf("Expected a brotli body, got %q", rec.Header().Get("Content-Encoding"))
+		}
+		if body, _ := io.ReadAll(brotli.NewReader(rec.Body)); string(body) != large {
+			t.Errorf("Body changed by compression")
+		}
+	})
+
+	t.Run("Below threshold", func(t *testing.T) {
+		rec := write(Gzip, "application/json", `{"status":"ok"}`)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != `{"status":"ok"}` {
+			t.Errorf("Expected a small body to pass through")
+		}
+		if rec.Code != http.StatusCreated {
+			t.Errorf("Expected the status to be kept, got %d", rec.Code)
+		}
+	})
+
+	t.Run("Other content type", func(t *testing.T) {
+		rec := write(Gzip, "image/png", large)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != large {
+			t.Errorf("Expected an image to pass through")
+		}
+	})
+}
+
+func TestFileServer(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte("body{}"))
+	zw.Close()
+
+	fsys := fstest.MapFS{
+		"css/main.css":    {Data: []byte("body{}")},
+		"css/main.css.gz": {Data: gz.Bytes()},
+	}
+	srv := FileServer(fsys)
+
+	get := func(accept string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/css/main.css", nil)
+		req.Header.Set("Accept-Encoding", accept)
+		srv.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("gzip, br")
+	if rec.Header().Get("Content-Encoding") != Gzip || !bytes.Equal(rec.Body.Bytes(), gz.Bytes()) {
+		t.Errorf("Expected the precompressed file, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/css") {
+		t.Errorf("Expected the content type of the original file, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	rec = get("")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "body{}" {
+		t.Errorf("Expected the plain file without Accept-Encoding")
+	}
+}
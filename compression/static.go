@@ -0,0 +1,63 @@
+package compression
+
+import (
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// extensions maps encodings to the suffix of their precompressed files
+var extensions = map[string]string{
+	Brotli: ".br",
+	Gzip:   ".gz",
+}
+
+// FileServer serves files from fsys, preferring a precompressed name.br or
+// name.gz next to the requested file when the client accepts it
+func FileServer(fsys fs.FS) http.Handler {
+	plain := http.FileServer(http.FS(fsys))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" || strings.HasSuffix(r.URL.Path, "/") {
+			plain.ServeHTTP(w, r)
+			return
+		}
+
+		var offered []string
+		for _, enc := range []string{Brotli, Gzip} {
+			if info, err := fs.Stat(fsys, name+extensions[enc]); err == nil && !info.IsDir() {
+				offered = append(offered, enc)
+			}
+		}
+		if len(offered) > 0 {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		enc := Negotiate(r.Header.Get("Accept-Encoding"), offered...)
+		if enc == "" {
+			plain.ServeHTTP(w, r)
+			return
+		}
+
+		f, err := fsys.Open(name + extensions[enc])
+		if err != nil {
+			plain.ServeHTTP(w, r)
+			return
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		rs, ok := f.(io.ReadSeeker)
+		if err != nil || !ok {
+			plain.ServeHTTP(w, r)
+			return
+		}
+
+		if ct := mime.TypeByExtension(path.Ext(name)); ct != "" {
+			w.Header().Set("Content-Type", ct)
+		}
+		w.Header().Set("Content-Encoding", enc)
+		http.ServeContent(w, r, name, info.ModTime(), rs)
+	})
+}
@@ -0,0 +1,145 @@
+// Package cors implements cross-origin resource sharing policies.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy decides which cross-origin requests browsers are allowed to make
+type Policy struct {
+	// AllowedOrigins are exact origins like "https://app.example.com", patterns
+	// with a single wildcard like "https://*.example.com", or "*" for any origin
+	AllowedOrigins []string
+	// AllowedMethods defaults to the methods registered for the route
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders are response headers scripts are allowed to read
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// Enabled reports whether the policy allows any origin at all
+func (p Policy) Enabled() bool {
+	return len(p.AllowedOrigins) > 0
+}
+
+// Validate checks the origin patterns. Any origin can't be combined with
+// credentials, that would let every site make requests with the user's cookies.
+func (p Policy) Validate() error {
+	for _, o := range p.AllowedOrigins {
+		if o == "*" && p.AllowCredentials {
+			return fmt.Errorf("origin %q can't be allowed with credentials, list the origins instead", o)
+		}
+		if o != "*" && strings.Count(o, "*") > 1 {
+			return fmt.Errorf("origin pattern %q may contain only one wildcard", o)
+		}
+	}
+	return nil
+}
+
+// AllowOrigin reports whether requests from origin are allowed
+func (p Policy) AllowOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, o := range p.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+		prefix, suffix, ok := strings.Cut(o, "*")
+		if ok && len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+			strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the headers for an actual cross-origin request. It returns false
+// when the origin isn't allowed, the response then carries no CORS headers.
+func (p Policy) Apply(h http.Header, origin string) bool {
+	h.Add("Vary", "Origin")
+	if !p.AllowOrigin(origin) {
+		return false
+	}
+	p.setOrigin(h, origin)
+	if len(p.ExposedHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+	}
+	return true
+}
+
+// Preflight adds the headers answering a preflight request for method and the
+// comma separated requestHeaders. routeMethods are the methods registered for
+// the path, used when the policy doesn't list its own.
+func (p Policy) Preflight(h http.Header, origin, method, requestHeaders string, routeMethods []string) bool {
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	if !p.AllowOrigin(origin) {
+		return false
+	}
+
+	methods := p.AllowedMethods
+	if len(methods) == 0 {
+		methods = routeMethods
+	}
+	if !slices.ContainsFunc(methods, func(m string) bool { return strings.EqualFold(m, method) }) {
+		return false
+	}
+	for _, rh := range ParseList(requestHeaders) {
+		if !p.allowHeader(rh) {
+			return false
+		}
+	}
+
+	p.setOrigin(h, origin)
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if requestHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+	return true
+}
+
+func (p Policy) setOrigin(h http.Header, origin string) {
+	// Validate rejects a wildcard with credentials, they are never sent along with it
+	if slices.Contains(p.AllowedOrigins, "*") {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p Policy) allowHeader(name string) bool {
+	for _, a := range p.AllowedHeaders {
+		if a == "*" || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseList splits a comma separated list and drops empty entries
+func ParseList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,105 @@
+package cors
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAllowOrigin(t *testing.T) {
+	p := Policy{AllowedOrigins: []string{"https://app.example.com", "https://*.tools.example.com"}}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"https://APP.example.com", true},
+		{"https://a.tools.example.com", true},
+		{"https://.tools.example.com", false},
+		{"https://tools.example.com", false},
+		{"http://a.tools.example.com", false},
+		{"https://evil.com", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := p.AllowOrigin(tt.origin); got != tt.want {
+			t.Errorf("AllowOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	if err := (Policy{AllowedOrigins: []string{"https://*.*.com"}}).Validate(); err == nil {
+		t.Errorf("Expected a pattern with two wildcards to be rejected")
+	}
+	if err := (Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Validate(); err == nil {
+		t.Errorf("Expected any origin with credentials to be rejected")
+	}
+	if err := (Policy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}).Validate(); err != nil {
+		t.Errorf("Expected a pattern with credentials to be accepted, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Run("Wildcard", func(t *testing.T) {
+		h := http.Header{}
+		p := Policy{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X-Version"}}
+		if !p.Apply(h, "https://any.example.com") {
+			t.Fatal("Expected the origin to be allowed")
+		}
+		if got := h.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Expected a wildcard origin, got %q", got)
+		}
+		if got := h.Get("Access-Control-Expose-Headers"); got != "X-Version" {
+			t.Errorf("Expected exposed headers, got %q", got)
+		}
+	})
+
+	t.Run("Wildcard with credentials", func(t *testing.T) {
+		h := http.Header{}
+		p := Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+		p.Apply(h, "https://any.example.com")
+		if got := h.Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("Expected the origin not to be echoed, got %q", got)
+		}
+		if got := h.Get("Access-Control-Allow-Credentials"); got != "" {
+			t.Errorf("Expected credentials not to be allowed, got %q", got)
+		}
+	})
+
+	t.Run("Not allowed", func(t *testing.T) {
+		h := http.Header{}
+		p := Policy{AllowedOrigins: []string{"https://app.example.com"}}
+		if p.Apply(h, "https://evil.com") || h.Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("Expected no CORS headers for a foreign origin")
+		}
+		if h.Get("Vary") != "Origin" {
+			t.Errorf("Expected Vary: Origin, got %q", h.Get("Vary"))
+		}
+	})
+}
+
+func TestPreflight(t *testing.T) {
+	p := Policy{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedHeaders: []string{"Authorization", "Content-Type"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	h := http.Header{}
+	if !p.Preflight(h, "https://app.example.com", "POST", "content-type, authorization", []string{"POST"}) {
+		t.Fatal("Expected the preflight to be allowed")
+	}
+	if got := h.Get("Access-Control-Allow-Methods"); got != "POST" {
+		t.Errorf("Expected the route's methods, got %q", got)
+	}
+	if got := h.Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Expected max age 600, got %q", got)
+	}
+
+	if p.Preflight(http.Header{}, "https://app.example.com", "DELETE", "", []string{"POST"}) {
+		t.Errorf("Expected a method the route doesn't have to be rejected")
+	}
+	if p.Preflight(http.Header{}, "https://app.example.com", "POST", "X-Custom", []string{"POST"}) {
+		t.Errorf("Expected a header that isn't allowed to be rejected")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+
+	"github.com/johansundell/template-service/requestid"
 )
 
 // Client is the OData client for FileMaker
@@ -23,6 +25,8 @@ func NewClient(config ClientConfig) *Client {
 	return &Client{
 		client: &http.Client{
 			Timeout: config.Timeout,
+			// Forward the request ID of the incoming request to FileMaker
+			Transport: requestid.Transport{Base: config.Transport},
 		},
 		config:  config,
 		baseURL: baseURL,
@@ -1,6 +1,9 @@
 package fmsodata
 
-import "time"
+import (
+	"net/http"
+	"time"
+)
 
 // ODataResponse represents a generic OData response
 type ODataResponse struct {
@@ -35,6 +38,8 @@ type ClientConfig struct {
 	Username string
 	Password string
 	Timeout  time.Duration
+	// Transport makes the HTTP calls, http.DefaultTransport when nil
+	Transport http.RoundTripper
 }
 
 // ScriptResult represents the result of a script execution
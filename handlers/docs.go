@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+)
+
+// APIDocs renders /openapi.json with Redoc. The page leaves out base.html,
+// whose styles would fight Redoc's.
+func (h *Handler) APIDocs(c *gin.Context) error {
+	tmpl, err := h.getTemplate(false, "docs.html")
+	if err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	data := map[string]interface{}{
+		"title":   h.nameOfService + " API",
+		"specUrl": "/openapi.json",
+	}
+	c.Status(http.StatusOK)
+	if err := tmpl.ExecuteTemplate(c.Writer, "docs", data); err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	return nil
+}
@@ -4,6 +4,9 @@ import (
 	"io/fs"
 	"text/template"
 
+	"github.com/johansundell/template-service/health"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/scheduler"
 	"github.com/johansundell/template-service/store"
 )
 
@@ -13,6 +16,9 @@ type Handler struct {
 	tpls             fs.FS
 	nameOfService    string
 	versionOfService string
+	health           *health.Registry
+	scheduler        *scheduler.Scheduler
+	maintenance      *maintenance.Mode
 }
 
 func NewHandler(s *store.Storage, ufs bool, f fs.FS, name, version string) *Handler {
@@ -22,9 +28,22 @@ func NewHandler(s *store.Storage, ufs bool, f fs.FS, name, version string) *Hand
 		tpls:             f,
 		nameOfService:    name,
 		versionOfService: version,
+		health:           health.NewRegistry(),
+		scheduler:        scheduler.New(),
+		maintenance:      maintenance.New("", 0, nil),
 	}
 }
 
+// SetHealthRegistry replaces the checks used by the readiness endpoint
+func (h *Handler) SetHealthRegistry(r *health.Registry) {
+	h.health = r
+}
+
+// SetScheduler replaces the scheduler behind the job endpoints
+func (h *Handler) SetScheduler(s *scheduler.Scheduler) {
+	h.scheduler = s
+}
+
 func (h *Handler) getTemplate(withBase bool, tmplFile ...string) (*template.Template, error) {
 	files := make([]string, len(tmplFile))
 	for k, t := range tmplFile {
@@ -46,3 +65,8 @@ func (h *Handler) getTemplate(withBase bool, tmplFile ...string) (*template.Temp
 	}
 	return template.ParseFS(h.tpls, files...)
 }
+
+// SetMaintenance replaces the maintenance mode behind the maintenance endpoints
+func (h *Handler) SetMaintenance(m *maintenance.Mode) {
+	h.maintenance = m
+}
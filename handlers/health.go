@@ -7,24 +7,40 @@ import (
 	"github.com/johansundell/template-service/httperror"
 )
 
+// HealthCheckResponse is the JSON form of the health page, its fields are
+// kept in the key order the page had as a map
+type HealthCheckResponse struct {
+	DBStatus string `json:"dbStatus"`
+	Name     string `json:"name"`
+	Title    string `json:"title"`
+	Version  string `json:"version"`
+}
+
 func (h *Handler) HealthCheck(c *gin.Context) error {
 	dbStatus := "OK"
 	if err := h.store.Ping(); err != nil {
 		dbStatus = err.Error()
 	}
 
-	data := map[string]interface{}{
-		"title":    "Health Check",
-		"name":     h.nameOfService,
-		"version":  h.versionOfService,
-		"dbStatus": dbStatus,
+	res := HealthCheckResponse{
+		Title:    "Health Check",
+		Name:     h.nameOfService,
+		Version:  h.versionOfService,
+		DBStatus: dbStatus,
 	}
 
 	if c.GetHeader("Accept") == "application/json" {
-		c.JSON(http.StatusOK, data)
+		c.JSON(http.StatusOK, res)
 		return nil
 	}
 
+	data := map[string]interface{}{
+		"title":    res.Title,
+		"name":     res.Name,
+		"version":  res.Version,
+		"dbStatus": res.DBStatus,
+	}
+
 	const tmplFile = "health.html"
 
 	tmpl, err := h.getTemplate(true, tmplFile)
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/scheduler"
+)
+
+// ListJobs returns the background jobs and the outcome of their last run
+func (h *Handler) ListJobs(c *gin.Context) error {
+	c.JSON(http.StatusOK, h.scheduler.Jobs())
+	return nil
+}
+
+// JobTriggeredResponse confirms that a job was started
+type JobTriggeredResponse struct {
+	Job    string `json:"job"`
+	Status string `json:"status"`
+}
+
+// RunJob triggers a background job outside of its schedule
+func (h *Handler) RunJob(c *gin.Context) error {
+	name := c.Param("name")
+	err := h.scheduler.Trigger(name)
+	switch {
+	case errors.Is(err, scheduler.ErrUnknownJob):
+		return httperror.ReturnWithHTTPStatus(err, http.StatusNotFound)
+	case errors.Is(err, scheduler.ErrJobRunning):
+		return httperror.ReturnWithHTTPStatus(err, http.StatusConflict)
+	case errors.Is(err, scheduler.ErrNotRunning):
+		return httperror.ReturnWithHTTPStatus(err, http.StatusServiceUnavailable)
+	case err != nil:
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	c.JSON(http.StatusAccepted, JobTriggeredResponse{Job: name, Status: "triggered"})
+	return nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/scheduler"
+)
+
+func TestRunJob(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	s := scheduler.New()
+	s.Add(scheduler.Job{Name: "noop", Spec: "@yearly", Func: func(ctx context.Context) error { return nil }})
+	s.Start()
+	defer s.Stop(context.Background())
+	h := &Handler{scheduler: s}
+
+	tests := []struct {
+		name       string
+		job        string
+		wantStatus int
+	}{
+		{"Known job", "noop", http.StatusAccepted},
+		{"Unknown job", "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("POST", "/admin/jobs/"+tt.job+"/run", nil)
+			c.Params = gin.Params{{Key: "name", Value: tt.job}}
+
+			err := h.RunJob(c)
+			status := w.Code
+			if err != nil {
+				status = httperror.HTTPStatus(err)
+			}
+			if status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, status)
+			}
+		})
+	}
+}
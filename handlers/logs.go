@@ -25,8 +25,12 @@ func (h *Handler) GetLogsHandler(c *gin.Context) error {
 
 	// Adjust 'to' date to include the entire day
 	to = to.Add(24 * time.Hour).Add(-1 * time.Second)
+	if to.After(time.Now()) {
+		// Logs are still being written to the range, keep it out of the response cache
+		c.Header("Cache-Control", "no-store")
+	}
 
-	logs, err := h.store.GetLogs(from, to)
+	logs, err := h.store.GetLogsContext(c, from, to)
 	if err != nil {
 		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
 	}
@@ -35,3 +39,17 @@ func (h *Handler) GetLogsHandler(c *gin.Context) error {
 	c.JSON(http.StatusOK, logs)
 	return nil
 }
+
+// GetLogsByRequestID returns the request logs written for an X-Request-ID
+func (h *Handler) GetLogsByRequestID(c *gin.Context) error {
+	logs, err := h.store.GetLogsByRequestID(c, c.Param("id"))
+	if err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	if len(logs) == 0 {
+		return httperror.ReturnWithHTTPStatus(errors.New("no logs for request id"), http.StatusNotFound)
+	}
+
+	c.JSON(http.StatusOK, logs)
+	return nil
+}
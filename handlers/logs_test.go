@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
 	"github.com/johansundell/template-service/store"
 	"github.com/johansundell/template-service/types"
 )
@@ -64,3 +65,48 @@ func TestGetLogsHandler(t *testing.T) {
 		t.Errorf("Expected 1 log, got %d", len(logs))
 	}
 }
+
+func TestGetLogsByRequestID(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create db: %v", err)
+	}
+	defer db.Close()
+	s := store.NewStorage(db)
+
+	if err := s.SaveLog(types.UsageLog{Status: 200, Method: "GET", Endpoint: "/test", CreatedAt: time.Now(), RequestID: "abc-123"}); err != nil {
+		t.Fatalf("Failed to insert log: %v", err)
+	}
+
+	h := NewHandler(s, false, fstest.MapFS{}, "test-service", "v1.0")
+
+	t.Run("Known request ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "abc-123"}}
+
+		if err := h.GetLogsByRequestID(c); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		var logs []types.UsageLog
+		if err := json.Unmarshal(w.Body.Bytes(), &logs); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		if len(logs) != 1 || logs[0].RequestID != "abc-123" {
+			t.Errorf("Expected the log for abc-123, got %+v", logs)
+		}
+	})
+
+	t.Run("Unknown request ID", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "missing"}}
+
+		if err := h.GetLogsByRequestID(c); httperror.HTTPStatus(err) != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/maintenance"
+)
+
+// MaintenanceRequest switches maintenance mode on or off
+type MaintenanceRequest struct {
+	Enabled *bool  `json:"enabled" binding:"required"`
+	Reason  string `json:"reason,omitempty" binding:"max=200" doc:"Shown to clients while the mode is on"`
+	// RetryAfter defaults to MAINTENANCE_RETRY_AFTER
+	RetryAfter int    `json:"retry_after,omitempty" binding:"gte=0,lte=86400" doc:"Seconds clients are told to wait"`
+	Label      string `json:"label,omitempty" binding:"max=100" doc:"Who made the change, recorded next to the client certificate or address"`
+}
+
+// GetMaintenance returns whether maintenance mode is on
+func (h *Handler) GetMaintenance(c *gin.Context) error {
+	c.JSON(http.StatusOK, h.maintenance.State())
+	return nil
+}
+
+// UpdateMaintenance switches maintenance mode on or off
+func (h *Handler) UpdateMaintenance(c *gin.Context) error {
+	var req MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusBadRequest)
+	}
+	// The label is the caller's word, the audit trail names the verified caller too
+	actor := c.GetString("clientIdentity")
+	if actor == "" {
+		actor = c.ClientIP()
+	}
+
+	state, err := h.maintenance.Set(c, *req.Enabled, req.Reason, req.RetryAfter, actor, req.Label)
+	if err != nil {
+		// The switch has been made, a broken audit trail shouldn't undo it
+		slog.ErrorContext(c, "Failed to record the maintenance toggle", "error", err)
+	}
+	c.JSON(http.StatusOK, state)
+	return nil
+}
+
+// maxAuditLimit caps how many audit entries a single request returns
+const maxAuditLimit = 1000
+
+// MaintenanceAudit returns the latest maintenance mode toggles, newest first
+func (h *Handler) MaintenanceAudit(c *gin.Context) error {
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			return httperror.ReturnWithHTTPStatus(fmt.Errorf("limit must be a number from 1 to %d", maxAuditLimit), http.StatusBadRequest)
+		}
+		limit = n
+	}
+	events, err := h.store.MaintenanceEvents(c, limit)
+	if err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	c.JSON(http.StatusOK, events)
+	return nil
+}
+
+// MaintenancePage renders the 503 page shown by routes during maintenance
+func (h *Handler) MaintenancePage(c *gin.Context, s maintenance.State) error {
+	tmpl, err := h.getTemplate(true, "maintenance.html")
+	if err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+
+	data := map[string]interface{}{
+		"title":  "Maintenance",
+		"name":   h.nameOfService,
+		"reason": s.Reason,
+	}
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusServiceUnavailable)
+	if err := tmpl.ExecuteTemplate(c.Writer, "base", data); err != nil {
+		return httperror.ReturnWithHTTPStatus(err, http.StatusInternalServerError)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/store"
+)
+
+func TestUpdateMaintenance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create db: %v", err)
+	}
+	defer db.Close()
+	s := store.NewStorage(db)
+	h := NewHandler(s, false, fstest.MapFS{}, "test-service", "v1.0")
+	h.SetMaintenance(maintenance.New("", 300, s))
+
+	update := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("PUT", "/admin/maintenance", strings.NewReader(body))
+		c.Request.RemoteAddr = "10.0.0.7:5000"
+		if err := h.UpdateMaintenance(c); err != nil {
+			t.Fatalf("UpdateMaintenance failed: %v", err)
+		}
+		return w
+	}
+
+	w := update(`{"enabled": true, "reason": "FileMaker upgrade"}`)
+	var state maintenance.State
+	json.Unmarshal(w.Body.Bytes(), &state)
+	if !state.Enabled || state.Reason != "FileMaker upgrade" || state.RetryAfter != 300 {
+		t.Errorf("Expected maintenance mode on, got %+v", state)
+	}
+	update(`{"enabled": false, "label": "alice", "actor": "mallory"}`)
+
+	w = httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/maintenance/audit", nil)
+	if err := h.MaintenanceAudit(c); err != nil {
+		t.Fatalf("MaintenanceAudit failed: %v", err)
+	}
+	var events []maintenance.Event
+	json.Unmarshal(w.Body.Bytes(), &events)
+	if len(events) != 2 || events[0].Actor != "10.0.0.7" || events[0].Label != "alice" || events[1].Actor != "10.0.0.7" || !events[1].Enabled {
+		t.Errorf("Unexpected audit trail %+v", events)
+	}
+
+	for _, limit := range []string{"abc", "-1", "0", "1001"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/admin/maintenance/audit?limit="+limit, nil)
+		if err := h.MaintenanceAudit(c); httperror.HTTPStatus(err) != http.StatusBadRequest {
+			t.Errorf("Expected 400 for limit %q, got %v", limit, err)
+		}
+	}
+}
+
+func TestMaintenancePage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockFS := fstest.MapFS{
+		"tmpl/base.html":        {Data: []byte(`{{define "base"}}{{template "content" .}}{{end}}`)},
+		"tmpl/maintenance.html": {Data: []byte(`{{define "content"}}{{.name}}: {{html .reason}}{{end}}`)},
+	}
+	h := NewHandler(nil, false, mockFS, "test-service", "v1.0")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	if err := h.MaintenancePage(c, maintenance.State{Enabled: true, Reason: "<b>upgrade</b>"}); err != nil {
+		t.Fatalf("MaintenancePage failed: %v", err)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", w.Code)
+	}
+	if w.Body.String() != "test-service: &lt;b&gt;upgrade&lt;/b&gt;" {
+		t.Errorf("Expected the escaped reason, got %q", w.Body.String())
+	}
+}
@@ -8,12 +8,15 @@ import (
 	"github.com/johansundell/template-service/httperror"
 )
 
+// PingResponse echoes the argument of a ping
+type PingResponse struct {
+	Result string `json:"result"`
+}
+
 func (h *Handler) Ping(c *gin.Context) error {
 	argument := c.Param("argument")
 
-	p := struct {
-		Result string `json:"result"`
-	}{Result: argument}
+	p := PingResponse{Result: argument}
 
 	if p.Result == "notfound" {
 		return httperror.ReturnWithHTTPStatus(errors.New("Nope"), http.StatusNotFound)
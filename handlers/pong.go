@@ -4,14 +4,28 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
 )
 
+// PongRequest is the body of a pong, the router checks its binding rules
+// before the handler runs
+type PongRequest struct {
+	Text  string   `json:"text,omitempty" binding:"max=200" doc:"Text to echo"`
+	Count int      `json:"count,omitempty" binding:"gte=0,lte=100"`
+	Tags  []string `json:"tags,omitempty" binding:"max=10,dive,min=1,max=50"`
+}
+
+// PongResponse echoes the body of a pong
+type PongResponse struct {
+	Message PongRequest `json:"message"`
+}
+
 func (h *Handler) Pong(c *gin.Context) error {
-	var input map[string]interface{}
+	var input PongRequest
 	if err := c.ShouldBindJSON(&input); err != nil {
-		return err
+		return httperror.ReturnWithHTTPStatus(err, http.StatusBadRequest)
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": input})
+	c.JSON(http.StatusOK, PongResponse{Message: input})
 	return nil
 }
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/health"
+)
+
+// LivenessResponse tells that the process is up and which version it runs
+type LivenessResponse struct {
+	Status  string `json:"status"`
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Liveness reports that the process is up and able to serve requests
+func (h *Handler) Liveness(c *gin.Context) error {
+	c.JSON(http.StatusOK, LivenessResponse{
+		Status:  health.StatusUp,
+		Name:    h.nameOfService,
+		Version: h.versionOfService,
+	})
+	return nil
+}
+
+// Readiness runs the registered health checks and answers 503 when a critical one fails
+func (h *Handler) Readiness(c *gin.Context) error {
+	report := h.health.Check(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+	return nil
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/health"
+)
+
+func TestReadiness(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Ready", func(t *testing.T) {
+		h := &Handler{health: health.NewRegistry()}
+		h.health.Register(health.Check{Name: "db", Critical: true, Func: func(ctx context.Context) error { return nil }})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/readyz", nil)
+
+		if err := h.Readiness(c); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Critical check failing", func(t *testing.T) {
+		h := &Handler{health: health.NewRegistry()}
+		h.health.Register(health.Check{Name: "db", Critical: true, Func: func(ctx context.Context) error { return errors.New("down") }})
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/readyz", nil)
+
+		if err := h.Readiness(c); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status 503, got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package health
+
+import (
+	"context"
+	"fmt"
+)
+
+// DiskSpaceCheck fails when the filesystem holding path has less than minFree bytes available
+func DiskSpaceCheck(path string, minFree uint64) CheckFunc {
+	return func(ctx context.Context) error {
+		free, err := freeDiskSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("only %d MB free on %s, want at least %d MB", free>>20, path, minFree>>20)
+		}
+		return nil
+	}
+}
+
+// QueueDepthCheck fails when the queue holds max entries or more
+func QueueDepthCheck(depth func() int, max int) CheckFunc {
+	return func(ctx context.Context) error {
+		if d := depth(); d >= max {
+			return fmt.Errorf("queue depth %d reached limit %d", d, max)
+		}
+		return nil
+	}
+}
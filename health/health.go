@@ -0,0 +1,187 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded"
+	StatusDown     = "down"
+)
+
+const (
+	defaultTimeout  = 2 * time.Second
+	defaultCacheTTL = 5 * time.Second
+)
+
+// CheckFunc reports the health of a single component, nil means healthy
+type CheckFunc func(ctx context.Context) error
+
+// Check is a named health check
+type Check struct {
+	Name string
+	// Critical checks take the service out of rotation when they fail
+	Critical bool
+	// Timeout bounds a single run of the check
+	Timeout time.Duration
+	// CacheTTL is how long a result is reused before the check runs again,
+	// a negative value disables the cache
+	CacheTTL time.Duration
+	Func     CheckFunc
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Critical  bool      `json:"critical"`
+	LatencyMs float64   `json:"latencyMs"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	Cached    bool      `json:"cached"`
+}
+
+// Report is the combined outcome of all checks in a registry
+type Report struct {
+	Status     string   `json:"status"`
+	Components []Result `json:"components"`
+}
+
+// Ready reports whether all critical checks passed
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+type entry struct {
+	check Check
+
+	mu     sync.Mutex
+	last   Result
+	hasRun bool
+}
+
+// Registry holds the registered health checks
+type Registry struct {
+	mu      sync.RWMutex
+	entries []*entry
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a check to the registry. Zero Timeout and CacheTTL get sensible defaults.
+func (r *Registry) Register(c Check) {
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.CacheTTL < 0 {
+		c.CacheTTL = 0
+	} else if c.CacheTTL == 0 {
+		c.CacheTTL = defaultCacheTTL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, &entry{check: c})
+}
+
+// Names returns the names of the registered checks
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.entries))
+	for i, e := range r.entries {
+		names[i] = e.check.Name
+	}
+	return names
+}
+
+// Check runs all checks concurrently and combines the results
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	entries := make([]*entry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.RUnlock()
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = e.run(ctx)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: results}
+	for _, res := range results {
+		if res.Status == StatusUp {
+			continue
+		}
+		if res.Critical {
+			report.Status = StatusDown
+		} else if report.Status == StatusUp {
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+func (e *entry) run(ctx context.Context) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.hasRun && time.Since(e.last.CheckedAt) < e.check.CacheTTL {
+		res := e.last
+		res.Cached = true
+		return res
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := runCheck(ctx, e.check.Func)
+	res := Result{
+		Name:      e.check.Name,
+		Status:    StatusUp,
+		Critical:  e.check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	e.last = res
+	e.hasRun = true
+	return res
+}
+
+// runCheck runs fn but gives up when ctx expires, even if fn ignores the context
+func runCheck(ctx context.Context, fn CheckFunc) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- fmt.Errorf("check panicked: %v", v)
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("check timed out: %w", ctx.Err())
+	}
+}
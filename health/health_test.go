@@ -0,0 +1,108 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryStatus(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	fail := func(ctx context.Context) error { return errors.New("broken") }
+
+	t.Run("All up", func(t *testing.T) {
+		r := NewRegistry()
+		r.Register(Check{Name: "db", Critical: true, Func: ok})
+		r.Register(Check{Name: "fms", Func: ok})
+
+		report := r.Check(context.Background())
+		if report.Status != StatusUp || !report.Ready() {
+			t.Errorf("Expected status up, got %s", report.Status)
+		}
+		if len(report.Components) != 2 {
+			t.Errorf("Expected 2 components, got %d", len(report.Components))
+		}
+	})
+
+	t.Run("Non-critical failure", func(t *testing.T) {
+		r := NewRegistry()
+		r.Register(Check{Name: "db", Critical: true, Func: ok})
+		r.Register(Check{Name: "fms", Func: fail})
+
+		report := r.Check(context.Background())
+		if report.Status != StatusDegraded || !report.Ready() {
+			t.Errorf("Expected status degraded, got %s", report.Status)
+		}
+		if report.Components[1].Error != "broken" {
+			t.Errorf("Expected error to be reported, got %q", report.Components[1].Error)
+		}
+	})
+
+	t.Run("Critical failure", func(t *testing.T) {
+		r := NewRegistry()
+		r.Register(Check{Name: "db", Critical: true, Func: fail})
+
+		report := r.Check(context.Background())
+		if report.Status != StatusDown || report.Ready() {
+			t.Errorf("Expected status down, got %s", report.Status)
+		}
+	})
+}
+
+func TestCheckTimeout(t *testing.T) {
+	r := NewRegistry()
+	r.Register(Check{
+		Name:     "slow",
+		Critical: true,
+		Timeout:  10 * time.Millisecond,
+		Func: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+
+	start := time.Now()
+	report := r.Check(context.Background())
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected check to give up after its timeout")
+	}
+	if report.Status != StatusDown {
+		t.Errorf("Expected status down, got %s", report.Status)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	calls := 0
+	r := NewRegistry()
+	r.Register(Check{
+		Name:     "counted",
+		CacheTTL: time.Minute,
+		Func: func(ctx context.Context) error {
+			calls++
+			return nil
+		},
+	})
+
+	r.Check(context.Background())
+	report := r.Check(context.Background())
+
+	if calls != 1 {
+		t.Errorf("Expected check to run once, ran %d times", calls)
+	}
+	if !report.Components[0].Cached {
+		t.Errorf("Expected second result to be cached")
+	}
+}
+
+func TestQueueDepthCheck(t *testing.T) {
+	depth := 5
+	check := QueueDepthCheck(func() int { return depth }, 10)
+	if err := check(context.Background()); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	depth = 10
+	if err := check(context.Background()); err == nil {
+		t.Errorf("Expected error when queue is full")
+	}
+}
@@ -0,0 +1,108 @@
+// Package idempotency lets a client retry a request with the same
+// Idempotency-Key and get the first response back instead of repeating its
+// side effects.
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Header is the request header carrying the client's key
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses served from the store
+const ReplayedHeader = "Idempotent-Replayed"
+
+// MaxKeyLength is the longest key accepted
+const MaxKeyLength = 255
+
+var (
+	// ErrInvalidKey is returned for an empty, too long or non-printable key
+	ErrInvalidKey = errors.New("idempotency key must be 1 to 255 printable characters")
+	// ErrMismatch is returned when a key is reused for a different request
+	ErrMismatch = errors.New("idempotency key was used for a different request")
+	// ErrInProgress is returned when the first request with a key hasn't finished in time
+	ErrInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// Response is a stored response
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// Record is the state of a key
+type Record struct {
+	// RequestHash identifies the request the key was first used for
+	RequestHash string
+	// Response is nil while the first request is still running
+	Response *Response
+}
+
+// Store keeps the records. Begin has to be atomic, it is the lock that lets
+// only one of several concurrent requests with a key run.
+type Store interface {
+	// Begin claims key for a request when it is new or expired, the claim
+	// lasts for lock. Otherwise it returns the key's record.
+	Begin(ctx context.Context, key, requestHash string, lock time.Duration) (rec Record, claimed bool, err error)
+	// Complete stores the response of a claimed key for ttl
+	Complete(ctx context.Context, key string, res Response, ttl time.Duration) error
+	// Release drops a claim without a response, so that the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// ValidKey reports whether key is usable
+func ValidKey(key string) bool {
+	if key == "" || len(key) > MaxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// Hash returns a fixed length hex digest of the parts, used for both the
+// scoped key and the request fingerprint
+func Hash(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write(p)
+		// Separates the parts, so that moving bytes between them changes the hash
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Wait retries Begin until the key is claimed or has a response. It gives up
+// with ErrInProgress when ctx ends first, and returns ErrMismatch as soon as
+// the key turns out to belong to another request.
+func Wait(ctx context.Context, s Store, key, requestHash string, lock, poll time.Duration) (Record, bool, error) {
+	for {
+		rec, claimed, err := s.Begin(ctx, key, requestHash, lock)
+		switch {
+		case err != nil:
+			return Record{}, false, err
+		case claimed:
+			return rec, true, nil
+		case rec.RequestHash != requestHash:
+			return rec, false, ErrMismatch
+		case rec.Response != nil:
+			return rec, false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return rec, false, ErrInProgress
+		case <-time.After(poll):
+		}
+	}
+}
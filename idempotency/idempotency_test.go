@@ -0,0 +1,91 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	for _, key := range []string{"a", "4f8d-11ee", strings.Repeat("k", MaxKeyLength)} {
+		if !ValidKey(key) {
+			t.Errorf("Expected %q to be valid", key)
+		}
+	}
+	for _, key := range []string{"", "tab\tkey", "åäö", strings.Repeat("k", MaxKeyLength+1)} {
+		if ValidKey(key) {
+			t.Errorf("Expected %q to be invalid", key)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	if Hash([]byte("ab"), []byte("c")) == Hash([]byte("a"), []byte("bc")) {
+		t.Errorf("Expected the parts to be kept apart")
+	}
+	if Hash([]byte("a")) != Hash([]byte("a")) {
+		t.Errorf("Expected the hash to be stable")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, claimed, _ := m.Begin(ctx, "key", "a", time.Minute); !claimed {
+		t.Fatalf("Expected the first request to claim the key")
+	}
+	if rec, claimed, _ := m.Begin(ctx, "key", "a", time.Minute); claimed || rec.Response != nil {
+		t.Fatalf("Expected a pending record, got %+v", rec)
+	}
+
+	m.Complete(ctx, "key", Response{Status: 200, Body: []byte("ok")}, time.Hour)
+	m.Release(ctx, "key")
+	rec, claimed, _ := m.Begin(ctx, "key", "b", time.Minute)
+	if claimed || rec.RequestHash != "a" || rec.Response == nil || string(rec.Response.Body) != "ok" {
+		t.Fatalf("Expected the stored response, got %+v", rec)
+	}
+
+	m.Begin(ctx, "other", "a", time.Minute)
+	m.Release(ctx, "other")
+	if _, claimed, _ := m.Begin(ctx, "other", "a", time.Minute); !claimed {
+		t.Errorf("Expected a released key to be claimable")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, claimed, _ := m.Begin(ctx, "key", "b", time.Minute); !claimed {
+		t.Errorf("Expected an expired key to be claimable")
+	}
+	if m.Len() != 1 {
+		t.Errorf("Expected expired records to be swept, got %d", m.Len())
+	}
+}
+
+func TestWait(t *testing.T) {
+	m := NewMemoryStore()
+	ctx := context.Background()
+	m.Begin(ctx, "key", "a", time.Minute)
+
+	if _, _, err := Wait(ctx, m, "key", "b", time.Minute, time.Millisecond); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected ErrMismatch, got %v", err)
+	}
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, _, err := Wait(short, m, "key", "a", time.Minute, time.Millisecond); !errors.Is(err, ErrInProgress) {
+		t.Errorf("Expected ErrInProgress, got %v", err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		m.Complete(ctx, "key", Response{Status: 201}, time.Hour)
+	}()
+	rec, claimed, err := Wait(ctx, m, "key", "a", time.Minute, time.Millisecond)
+	if err != nil || claimed || rec.Response == nil || rec.Response.Status != 201 {
+		t.Errorf("Expected to wait for the response, got %+v, %v, %v", rec, claimed, err)
+	}
+}
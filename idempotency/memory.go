@@ -0,0 +1,90 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often expired records are dropped from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps records in memory, so keys are only known to one process
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryRecord
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryRecord struct {
+	Record
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: map[string]memoryRecord{},
+		now:     time.Now,
+	}
+}
+
+// Begin claims key when it is new or expired, otherwise it returns its record
+func (m *MemoryStore) Begin(ctx context.Context, key, requestHash string, lock time.Duration) (Record, bool, error) {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, r := range m.records {
+			if !now.Before(r.expires) {
+				delete(m.records, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	if r, ok := m.records[key]; ok && now.Before(r.expires) {
+		return r.Record, false, nil
+	}
+	rec := Record{RequestHash: requestHash}
+	m.records[key] = memoryRecord{Record: rec, expires: now.Add(lock)}
+	return rec, true, nil
+}
+
+// Complete stores the response of a claimed key
+func (m *MemoryStore) Complete(ctx context.Context, key string, res Response, ttl time.Duration) error {
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.records[key]
+	if !ok {
+		return nil
+	}
+	r.Response = &res
+	r.expires = now.Add(ttl)
+	m.records[key] = r
+	return nil
+}
+
+// Release drops a claim that has no response yet
+func (m *MemoryStore) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.records[key]; ok && r.Response == nil {
+		delete(m.records, key)
+	}
+	return nil
+}
+
+// Len returns the number of records held in memory
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.records)
+}
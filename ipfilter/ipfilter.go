@@ -0,0 +1,198 @@
+// Package ipfilter resolves the client address behind trusted proxies and
+// checks it against CIDR allow and deny lists.
+package ipfilter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// ParsePrefixes reads a list of CIDR ranges and single addresses, separated by
+// commas, pipes or spaces
+func ParsePrefixes(spec string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	fields := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '|' || r == ' ' })
+	for _, f := range fields {
+		if strings.Contains(f, "/") {
+			p, err := netip.ParsePrefix(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR range %q", f)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", f)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Unix stands for the peers on a unix socket in a list of trusted proxies
+const Unix = "unix"
+
+// ParseTrusted reads a list of trusted proxies like ParsePrefixes. The Unix
+// token trusts peers on a unix socket, which have no address of their own.
+func ParseTrusted(spec string) (prefixes []netip.Prefix, unix bool, err error) {
+	var rest []string
+	for _, f := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '|' || r == ' ' }) {
+		if f == Unix {
+			unix = true
+			continue
+		}
+		rest = append(rest, f)
+	}
+	prefixes, err = ParsePrefixes(strings.Join(rest, ","))
+	return prefixes, unix, err
+}
+
+// contains reports whether any of the prefixes holds addr
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
+
+// List decides which clients may connect. Deny wins over Allow, and an empty
+// Allow lets in every address that isn't denied.
+type List struct {
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// Enabled reports whether the list restricts anything
+func (l List) Enabled() bool {
+	return len(l.Allow) > 0 || len(l.Deny) > 0
+}
+
+// Allows reports whether addr may connect. An unknown address, like that of a
+// unix socket peer, is only let in when there is no allow list.
+func (l List) Allows(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return len(l.Allow) == 0
+	}
+	if contains(l.Deny, addr) {
+		return false
+	}
+	return len(l.Allow) == 0 || contains(l.Allow, addr)
+}
+
+// Header names the resolver understands
+const (
+	Forwarded     = "Forwarded"
+	XForwardedFor = "X-Forwarded-For"
+	XRealIP       = "X-Real-IP"
+)
+
+// Resolver finds the client address of a request. Forwarding headers are
+// only believed when the connection comes from a trusted proxy.
+type Resolver struct {
+	// Trusted are the proxies allowed to report the client address
+	Trusted []netip.Prefix
+	// TrustUnix allows the peers on a unix socket to report it, like a proxy
+	// on the same host that connects to a socket in PORT
+	TrustUnix bool
+	// Headers are read in order, the first one present is used
+	Headers []string
+}
+
+// ClientIP returns the address of the client. The addresses a forwarding
+// header lists are walked from the right, the first one that isn't a trusted
+// proxy is the client. A malformed entry stops the walk at the connecting
+// peer, so that clients can't pick their address.
+func (r Resolver) ClientIP(req *http.Request) netip.Addr {
+	peer := remoteAddr(req.RemoteAddr)
+	if !r.trusted(req, peer) {
+		return peer
+	}
+
+	for _, name := range r.Headers {
+		values := req.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		hops, ok := parseHops(name, values)
+		if !ok || len(hops) == 0 {
+			return peer
+		}
+		for i := len(hops) - 1; i >= 0; i-- {
+			if !contains(r.Trusted, hops[i]) {
+				return hops[i]
+			}
+		}
+		// Every hop is a trusted proxy, the first one is as close to the client as it gets
+		return hops[0]
+	}
+	return peer
+}
+
+// trusted reports whether the peer of req may report the client address
+func (r Resolver) trusted(req *http.Request, peer netip.Addr) bool {
+	if peer.IsValid() {
+		return contains(r.Trusted, peer)
+	}
+	return r.TrustUnix && unixSocket(req)
+}
+
+// unixSocket reports whether req came in on a unix socket
+func unixSocket(req *http.Request) bool {
+	addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// remoteAddr parses the address of http.Request.RemoteAddr, it is invalid for unix sockets
+func remoteAddr(s string) netip.Addr {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap()
+	}
+	addr, _ := netip.ParseAddr(s)
+	return addr.Unmap()
+}
+
+// parseHops reads the addresses listed by a forwarding header, client first
+func parseHops(name string, values []string) ([]netip.Addr, bool) {
+	var hops []netip.Addr
+	for _, v := range values {
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if http.CanonicalHeaderKey(name) == Forwarded {
+				entry = forwardedFor(entry)
+			}
+			addr, ok := parseHop(entry)
+			if !ok {
+				return nil, false
+			}
+			hops = append(hops, addr)
+		}
+	}
+	return hops, true
+}
+
+// forwardedFor returns the for= parameter of a Forwarded element, RFC 7239
+func forwardedFor(element string) string {
+	for _, pair := range strings.Split(element, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if ok && strings.EqualFold(k, "for") {
+			return strings.Trim(v, `"`)
+		}
+	}
+	return ""
+}
+
+// parseHop parses an address with or without a port, IPv6 may be in brackets
+func parseHop(s string) (netip.Addr, bool) {
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
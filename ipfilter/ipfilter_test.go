@@ -0,0 +1,130 @@
+package ipfilter
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestParsePrefixes(t *testing.T) {
+	got, err := ParsePrefixes("10.0.0.0/8, 192.168.1.7|2001:db8::/32 ::ffff:172.16.0.1")
+	if err != nil {
+		t.Fatalf("ParsePrefixes failed: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "2001:db8::/32", "172.16.0.1/32"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i].String() != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], got[i])
+		}
+	}
+
+	for _, spec := range []string{"10.0.0.0/33", "office", "10.0.0"} {
+		if _, err := ParsePrefixes(spec); err == nil {
+			t.Errorf("Expected ParsePrefixes(%q) to fail", spec)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	allow, _ := ParsePrefixes("10.0.0.0/8")
+	deny, _ := ParsePrefixes("10.0.5.0/24")
+	l := List{Allow: allow, Deny: deny}
+
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+		{"10.0.5.1", false},
+		{"192.168.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := l.Allows(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+	if l.Allows(netip.Addr{}) {
+		t.Errorf("Expected an unknown address to be refused by an allow list")
+	}
+	if !(List{Deny: deny}).Allows(netip.Addr{}) {
+		t.Errorf("Expected an unknown address to pass a deny list")
+	}
+}
+
+func TestResolver(t *testing.T) {
+	trusted, _ := ParsePrefixes("10.0.0.0/8")
+	r := Resolver{Trusted: trusted, Headers: []string{Forwarded, XForwardedFor, XRealIP}}
+
+	tests := []struct {
+		name   string
+		remote string
+		header map[string]string
+		want   string
+	}{
+		{"direct", "203.0.113.9:5000", nil, "203.0.113.9"},
+		{"untrusted peer", "203.0.113.9:5000", map[string]string{XForwardedFor: "198.51.100.1"}, "203.0.113.9"},
+		{"x-forwarded-for", "10.0.0.1:5000", map[string]string{XForwardedFor: "198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"spoofed hop", "10.0.0.1:5000", map[string]string{XForwardedFor: "1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"x-real-ip", "10.0.0.1:5000", map[string]string{XRealIP: "198.51.100.1"}, "198.51.100.1"},
+		{"forwarded", "10.0.0.1:5000", map[string]string{Forwarded: `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`}, "2001:db8::1"},
+		{"forwarded wins", "10.0.0.1:5000", map[string]string{Forwarded: "for=198.51.100.1", XForwardedFor: "198.51.100.2"}, "198.51.100.1"},
+		{"malformed", "10.0.0.1:5000", map[string]string{XForwardedFor: "198.51.100.1, garbage"}, "10.0.0.1"},
+		{"obfuscated", "10.0.0.1:5000", map[string]string{Forwarded: "for=_hidden"}, "10.0.0.1"},
+		{"only proxies", "10.0.0.1:5000", map[string]string{XForwardedFor: "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"ipv6 peer", "[::1]:5000", nil, "::1"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		if got := r.ClientIP(req).String(); got != tt.want {
+			t.Errorf("%s: ClientIP = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "@"
+	if r.ClientIP(req).IsValid() {
+		t.Errorf("Expected no address for a unix socket peer")
+	}
+}
+
+func TestResolverUnixSocket(t *testing.T) {
+	trusted, unix, err := ParseTrusted("10.0.0.0/8, unix")
+	if err != nil || !unix || len(trusted) != 1 {
+		t.Fatalf("Expected a range and the unix token, got %v %v %v", trusted, unix, err)
+	}
+	if _, _, err := ParseTrusted("unix, garbage"); err == nil {
+		t.Errorf("Expected an invalid address next to the unix token to fail")
+	}
+
+	call := func(r Resolver, local net.Addr) netip.Addr {
+		req := httptest.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, local))
+		req.RemoteAddr = "@"
+		req.Header.Set(XForwardedFor, "198.51.100.1, 10.0.0.2")
+		return r.ClientIP(req)
+	}
+	socket := &net.UnixAddr{Name: "/run/template-service.sock", Net: "unix"}
+
+	r := Resolver{Trusted: trusted, TrustUnix: true, Headers: []string{XForwardedFor}}
+	if got := call(r, socket); got.String() != "198.51.100.1" {
+		t.Errorf("Expected the forwarded address from a trusted unix socket peer, got %s", got)
+	}
+	if got := call(r, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080}); got.IsValid() {
+		t.Errorf("Expected a peer without an address on TCP not to be trusted, got %s", got)
+	}
+	r.TrustUnix = false
+	if got := call(r, socket); got.IsValid() {
+		t.Errorf("Expected the headers to be ignored without the unix token, got %s", got)
+	}
+}
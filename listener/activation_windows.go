@@ -0,0 +1,8 @@
+//go:build windows
+
+package listener
+
+// Activated always returns nothing, socket activation is a systemd feature
+func Activated() ([]Socket, error) {
+	return nil, nil
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package listener
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+)
+
+func chgrp(path, group string) error {
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return err
+	}
+	gid, err := strconv.Atoi(g.Gid)
+	if err != nil {
+		return err
+	}
+	return os.Lchown(path, -1, gid)
+}
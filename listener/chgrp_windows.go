@@ -0,0 +1,9 @@
+//go:build windows
+
+package listener
+
+import "errors"
+
+func chgrp(path, group string) error {
+	return errors.New("setting the socket group is not supported on windows")
+}
@@ -0,0 +1,99 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// UnixPrefix marks an address as a Unix domain socket path, as in "unix:/run/app.sock"
+const UnixPrefix = "unix:"
+
+// Options controls how Unix domain sockets are created
+type Options struct {
+	// Mode is applied to the socket file, zero leaves the umask default
+	Mode os.FileMode
+	// Group owns the socket file when set, so e.g. nginx can connect
+	Group string
+}
+
+// IsUnix reports whether addr is a Unix domain socket address
+func IsUnix(addr string) bool {
+	return strings.HasPrefix(addr, UnixPrefix)
+}
+
+// Listen opens a TCP listener, or a Unix domain socket when addr starts with "unix:"
+func Listen(addr string, opts Options) (net.Listener, error) {
+	if !IsUnix(addr) {
+		return net.Listen("tcp", addr)
+	}
+
+	path := strings.TrimPrefix(addr, UnixPrefix)
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Mode != 0 {
+		if err := os.Chmod(path, opts.Mode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("chmod %s: %w", path, err)
+		}
+	}
+	if opts.Group != "" {
+		if err := chgrp(path, opts.Group); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("chgrp %s: %w", path, err)
+		}
+	}
+	return ln, nil
+}
+
+// removeStaleSocket deletes a socket file left behind by a crashed process.
+// A socket that still accepts connections belongs to someone else and is left alone.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Socket is a listener passed in by the service manager
+type Socket struct {
+	// Name is the FileDescriptorName= of the systemd socket unit
+	Name string
+	net.Listener
+}
+
+// Find returns the first socket with the given name
+func Find(sockets []Socket, name string) (net.Listener, bool) {
+	for _, s := range sockets {
+		if s.Name == name {
+			return s.Listener, true
+		}
+	}
+	return nil, false
+}
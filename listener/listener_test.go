@@ -0,0 +1,57 @@
+package listener
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	ln, err := Listen(UnixPrefix+path, Options{Mode: 0600})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Socket file missing: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	if _, err := Listen(UnixPrefix+path, Options{}); err == nil {
+		t.Errorf("Expected error when the socket is in use")
+	}
+	ln.Close()
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+
+	// Leave a socket file behind without anyone listening on it
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	ln, err = Listen(UnixPrefix+path, Options{})
+	if err != nil {
+		t.Fatalf("Expected stale socket to be replaced, got %v", err)
+	}
+	ln.Close()
+}
+
+func TestListenRefusesRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.sock")
+	os.WriteFile(path, []byte("data"), 0600)
+
+	if _, err := Listen(UnixPrefix+path, Options{}); err == nil {
+		t.Errorf("Expected error when the path is not a socket")
+	}
+}
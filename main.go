@@ -2,10 +2,10 @@ package main
 
 import (
 	"embed"
-	"flag"
 	"log"
+	"os"
 
-	"github.com/kardianos/service"
+	"github.com/johansundell/template-service/server"
 )
 
 const (
@@ -14,52 +14,21 @@ const (
 
 var Version = "dev"
 
-//go:embed tmpl/*.html
-var tpls embed.FS
-
+// Precompressed .gz and .br copies of the assets are generated before the build
+//
+//go:generate go run ./tools/precompress assets
 //go:embed assets/*
 var embededFiles embed.FS
 
 func main() {
-	svcFlag := flag.String("service", "", "Control the system service.")
-	flag.Parse()
-
-	svcConfig := &service.Config{
-		Name:        nameOfService,
-		DisplayName: nameOfService,
-		Description: nameOfService,
-	}
-
-	prg := &program{}
-	s, err := service.New(prg, svcConfig)
-	if err != nil {
-		log.Fatal(err)
-	}
-	errs := make(chan error, 5)
-	logger, err = s.Logger(errs)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	go func() {
-		for {
-			err := <-errs
-			if err != nil {
-				log.Print(err)
-			}
-		}
-	}()
-
-	if len(*svcFlag) != 0 {
-		err := service.Control(s, *svcFlag)
-		if err != nil {
-			log.Printf("Valid actions: %q\n", service.ControlAction)
-			log.Fatal(err)
-		}
-		return
-	}
-	err = s.Run()
-	if err != nil {
-		logger.Error(err)
+	srv := server.New(
+		server.WithName(nameOfService),
+		server.WithVersion(Version),
+		server.WithAssets(embededFiles),
+		server.WithDefaultRoutes(),
+	)
+	if err := srv.Run(os.Args[1:]); err != nil {
+		log.Print(err)
+		os.Exit(1)
 	}
 }
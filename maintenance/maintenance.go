@@ -0,0 +1,180 @@
+// Package maintenance keeps track of whether the service is in maintenance
+// mode and records who switched it.
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sources of a toggle
+const (
+	// SourceAPI is a toggle through the admin endpoint, the CLI uses it too
+	SourceAPI = "api"
+	// SourceFile is the flag file appearing or disappearing
+	SourceFile = "file"
+)
+
+// State is whether maintenance mode is on, and why
+type State struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	// RetryAfter is what clients are told to wait, in seconds
+	RetryAfter int       `json:"retry_after,omitempty"`
+	Since      time.Time `json:"since,omitempty"`
+	Source     string    `json:"source,omitempty"`
+}
+
+// Event is an entry of the audit trail. Actor is who the caller was verified
+// to be, its client certificate or address, and Label what the caller says
+// about itself, e.g. the user running the CLI.
+type Event struct {
+	ID         int       `json:"id"`
+	Enabled    bool      `json:"enabled"`
+	Reason     string    `json:"reason,omitempty"`
+	RetryAfter int       `json:"retry_after,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	Label      string    `json:"label,omitempty"`
+	Source     string    `json:"source"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Recorder keeps the audit trail
+type Recorder interface {
+	RecordMaintenance(ctx context.Context, e Event) error
+}
+
+// Mode is switched on through Set or by the flag file, either one is enough
+type Mode struct {
+	mu         sync.RWMutex
+	manual     State
+	file       State
+	path       string
+	retryAfter int
+	recorder   Recorder
+	now        func() time.Time
+}
+
+// New creates a mode that is off. path is the flag file, none when empty,
+// and retryAfter the default wait in seconds.
+func New(path string, retryAfter int, rec Recorder) *Mode {
+	return &Mode{path: path, retryAfter: retryAfter, recorder: rec, now: time.Now}
+}
+
+// State returns the current state, a toggle through Set wins over the flag file
+func (m *Mode) State() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.manual.Enabled {
+		return m.manual
+	}
+	if m.file.Enabled {
+		return m.file
+	}
+	return State{}
+}
+
+// Enabled reports whether maintenance mode is on
+func (m *Mode) Enabled() bool {
+	return m.State().Enabled
+}
+
+// Set switches maintenance mode on or off and records it in the audit trail.
+// retryAfter of zero uses the default. Turning it off doesn't remove a flag
+// file, the mode stays on until the file is gone.
+func (m *Mode) Set(ctx context.Context, enabled bool, reason string, retryAfter int, actor, label string) (State, error) {
+	if retryAfter <= 0 {
+		retryAfter = m.retryAfter
+	}
+	now := m.now()
+
+	m.mu.Lock()
+	if enabled {
+		m.manual = State{Enabled: true, Reason: reason, RetryAfter: retryAfter, Since: now, Source: SourceAPI}
+	} else {
+		m.manual = State{}
+	}
+	m.mu.Unlock()
+
+	err := m.record(ctx, Event{Enabled: enabled, Reason: reason, RetryAfter: retryAfter, Actor: actor, Label: label, Source: SourceAPI, CreatedAt: now})
+	return m.State(), err
+}
+
+// Restore brings back the toggle of the last API event in the audit trail,
+// so that a restart during maintenance doesn't end it. It records nothing.
+func (m *Mode) Restore(last Event) {
+	if last.Source != SourceAPI {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if last.Enabled {
+		m.manual = State{Enabled: true, Reason: last.Reason, RetryAfter: last.RetryAfter, Since: last.CreatedAt, Source: SourceAPI}
+	} else {
+		m.manual = State{}
+	}
+}
+
+// CheckFile updates the state from the flag file. The first line of the file
+// is used as the reason. Changes are recorded in the audit trail.
+func (m *Mode) CheckFile(ctx context.Context) error {
+	if m.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.path)
+	present := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	reason, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	reason = strings.TrimSpace(reason)
+	now := m.now()
+
+	m.mu.Lock()
+	changed := present != m.file.Enabled || (present && reason != m.file.Reason)
+	if present {
+		if changed {
+			m.file = State{Enabled: true, Reason: reason, RetryAfter: m.retryAfter, Since: now, Source: SourceFile}
+		}
+	} else {
+		m.file = State{}
+	}
+	m.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	return m.record(ctx, Event{Enabled: present, Reason: reason, RetryAfter: m.retryAfter, Source: SourceFile, CreatedAt: now})
+}
+
+// Watch checks the flag file every interval until ctx is done
+func (m *Mode) Watch(ctx context.Context, interval time.Duration) {
+	if m.path == "" {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := m.CheckFile(ctx); err != nil {
+			slog.ErrorContext(ctx, "Failed to check the maintenance flag file", "path", m.path, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (m *Mode) record(ctx context.Context, e Event) error {
+	if m.recorder == nil {
+		return nil
+	}
+	return m.recorder.RecordMaintenance(ctx, e)
+}
@@ -0,0 +1,114 @@
+package maintenance
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type recorder []Event
+
+func (r *recorder) RecordMaintenance(ctx context.Context, e Event) error {
+	*r = append(*r, e)
+	return nil
+}
+
+func TestSet(t *testing.T) {
+	var rec recorder
+	m := New("", 300, &rec)
+	ctx := context.Background()
+
+	if m.Enabled() {
+		t.Fatalf("Expected maintenance mode to start off")
+	}
+	s, err := m.Set(ctx, true, "database upgrade", 0, "alice", "")
+	if err != nil || !s.Enabled || s.RetryAfter != 300 || s.Source != SourceAPI {
+		t.Fatalf("Expected maintenance mode on with the default wait, got %+v, %v", s, err)
+	}
+	if s, _ := m.Set(ctx, false, "", 0, "alice", ""); s.Enabled {
+		t.Errorf("Expected maintenance mode off")
+	}
+
+	if len(rec) != 2 || !rec[0].Enabled || rec[0].Actor != "alice" || rec[0].Reason != "database upgrade" || rec[1].Enabled {
+		t.Errorf("Unexpected audit trail %+v", rec)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	m := New("", 300, nil)
+	m.Restore(Event{Enabled: true, Reason: "upgrade", RetryAfter: 60, Source: SourceAPI})
+	if s := m.State(); !s.Enabled || s.Reason != "upgrade" || s.RetryAfter != 60 {
+		t.Errorf("Expected the last toggle to be restored, got %+v", s)
+	}
+
+	m = New("", 300, nil)
+	m.Restore(Event{Enabled: true, Source: SourceFile})
+	if m.Enabled() {
+		t.Errorf("Expected file events not to be restored")
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	var rec recorder
+	path := filepath.Join(t.TempDir(), "maintenance")
+	m := New(path, 120, &rec)
+	ctx := context.Background()
+
+	if err := m.CheckFile(ctx); err != nil || m.Enabled() || len(rec) != 0 {
+		t.Fatalf("Expected no change without the file, got %v, %v", m.Enabled(), err)
+	}
+
+	os.WriteFile(path, []byte("FileMaker upgrade\nuntil 22:00\n"), 0o644)
+	m.CheckFile(ctx)
+	s := m.State()
+	if !s.Enabled || s.Reason != "FileMaker upgrade" || s.Source != SourceFile || s.RetryAfter != 120 {
+		t.Errorf("Expected the file to switch maintenance mode on, got %+v", s)
+	}
+	m.CheckFile(ctx)
+	if len(rec) != 1 {
+		t.Errorf("Expected an unchanged file to be recorded once, got %+v", rec)
+	}
+
+	// A toggle through the API wins while it is on
+	m.Set(ctx, true, "manual", 10, "bob", "")
+	if s := m.State(); s.Reason != "manual" {
+		t.Errorf("Expected the API toggle to win, got %+v", s)
+	}
+	m.Set(ctx, false, "", 0, "bob", "")
+	if !m.Enabled() {
+		t.Errorf("Expected the file to keep maintenance mode on")
+	}
+
+	os.Remove(path)
+	m.CheckFile(ctx)
+	if m.Enabled() {
+		t.Errorf("Expected removing the file to end maintenance mode")
+	}
+	if last := rec[len(rec)-1]; last.Enabled || last.Source != SourceFile {
+		t.Errorf("Expected the removal to be recorded, got %+v", last)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "maintenance")
+	m := New(path, 120, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.Watch(ctx, 5*time.Millisecond)
+		close(done)
+	}()
+
+	os.WriteFile(path, nil, 0o644)
+	deadline := time.Now().Add(time.Second)
+	for !m.Enabled() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !m.Enabled() {
+		t.Errorf("Expected the watcher to notice the flag file")
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,181 @@
+// Package metrics collects the service's Prometheus metrics.
+package metrics
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "template_service"
+
+// Metrics holds the collectors of a service instance in their own registry
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	inFlight        *prometheus.GaugeVec
+	panics          *prometheus.CounterVec
+
+	fmsCalls    *prometheus.CounterVec
+	fmsDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics with Go runtime, process and build info collectors registered
+func New(version string) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route, method and status class.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time spent handling HTTP requests, by route, method and status class.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests currently being handled, by route and method.",
+		}, []string{"route", "method"}),
+		panics: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "panics_total",
+			Help:      "Panics recovered while handling HTTP requests, by route.",
+		}, []string{"route"}),
+		fmsCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "filemaker_requests_total",
+			Help:      "Calls to the FileMaker OData API, by method and status class.",
+		}, []string{"method", "status"}),
+		fmsDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "filemaker_request_duration_seconds",
+			Help:      "Latency of calls to the FileMaker OData API, by method and status class.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "status"}),
+	}
+
+	buildInfo := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information, the value is always 1.",
+	}, []string{"version", "goversion"})
+	buildInfo.WithLabelValues(version, runtime.Version()).Set(1)
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		buildInfo,
+		m.requests,
+		m.requestDuration,
+		m.inFlight,
+		m.panics,
+		m.fmsCalls,
+		m.fmsDuration,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns the registry, for registering further collectors
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// StartRequest counts a request as in flight, the returned function records it once finished
+func (m *Metrics) StartRequest(route, method string) func(status int) {
+	start := time.Now()
+	inFlight := m.inFlight.WithLabelValues(route, method)
+	inFlight.Inc()
+	return func(status int) {
+		inFlight.Dec()
+		class := StatusClass(status)
+		m.requests.WithLabelValues(route, method, class).Inc()
+		m.requestDuration.WithLabelValues(route, method, class).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Panic counts a panic recovered while handling a request on route
+func (m *Metrics) Panic(route string) {
+	m.panics.WithLabelValues(route).Inc()
+}
+
+// RegisterDB exports the connection pool stats of db
+func (m *Metrics) RegisterDB(db *sql.DB, name string) error {
+	return m.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterLogQueue exports the depth, capacity and dropped entries of the request log queue
+func (m *Metrics) RegisterLogQueue(depth func() int, capacity int, dropped func() uint64) error {
+	for _, c := range []prometheus.Collector{
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "log_queue_depth",
+			Help:      "Request logs waiting to be written.",
+		}, func() float64 { return float64(depth()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "log_queue_capacity",
+			Help:      "Request logs the queue can hold.",
+		}, func() float64 { return float64(capacity) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "log_queue_dropped_total",
+			Help:      "Request logs dropped because the queue was full or closed.",
+		}, func() float64 { return float64(dropped()) }),
+	} {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Transport records the count and latency of FileMaker calls made through base,
+// http.DefaultTransport when nil
+func (m *Metrics) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := base.RoundTrip(req)
+		status := "error"
+		if err == nil {
+			status = StatusClass(resp.StatusCode)
+		}
+		m.fmsCalls.WithLabelValues(req.Method, status).Inc()
+		m.fmsDuration.WithLabelValues(req.Method, status).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// StatusClass turns a status code into a label like "2xx"
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return strconv.Itoa(status)
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
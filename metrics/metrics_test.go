@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
+
+func TestMetrics(t *testing.T) {
+	m := New("v1.2.3")
+
+	done := m.StartRequest("Ping", "GET")
+	if out := scrape(t, m); !strings.Contains(out, `template_service_http_requests_in_flight{method="GET",route="Ping"} 1`) {
+		t.Errorf("Expected the request to be in flight:\n%s", out)
+	}
+	done(http.StatusNotFound)
+	m.Panic("Ping")
+
+	var queued, dropped = 3, uint64(2)
+	if err := m.RegisterLogQueue(func() int { return queued }, 10, func() uint64 { return dropped }); err != nil {
+		t.Fatalf("RegisterLogQueue failed: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	resp, err := (&http.Client{Transport: m.Transport(nil)}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	out := scrape(t, m)
+	for _, want := range []string{
+		`template_service_http_requests_total{method="GET",route="Ping",status="4xx"} 1`,
+		`template_service_http_requests_in_flight{method="GET",route="Ping"} 0`,
+		`template_service_http_request_duration_seconds_count{method="GET",route="Ping",status="4xx"} 1`,
+		`template_service_build_info{goversion="`,
+		`version="v1.2.3"} 1`,
+		`template_service_panics_total{route="Ping"} 1`,
+		`template_service_log_queue_depth 3`,
+		`template_service_log_queue_dropped_total 2`,
+		`template_service_filemaker_requests_total{method="GET",status="2xx"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in the metrics", want)
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, want := range map[int]string{200: "2xx", 304: "3xx", 429: "4xx", 504: "5xx", 0: "0"} {
+		if got := StatusClass(status); got != want {
+			t.Errorf("StatusClass(%d) = %q, want %q", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ApplyRules adds the constraints of go-playground/validator rules, as used
+// in binding tags, to s and reports whether they make the field required.
+// Rules after dive apply to the items of an array.
+func ApplyRules(s *Schema, rules string) bool {
+	required := false
+	for i, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			if s.Items != nil {
+				ApplyRules(s.Items, strings.Join(strings.Split(rules, ",")[i+1:], ","))
+			} else if s.AdditionalProperties != nil {
+				ApplyRules(s.AdditionalProperties, strings.Join(strings.Split(rules, ",")[i+1:], ","))
+			}
+			return required
+		case "required":
+			required = true
+		case "min", "gte":
+			s.setMin(param, false)
+		case "max", "lte":
+			s.setMax(param, false)
+		case "gt":
+			s.setMin(param, true)
+		case "lt":
+			s.setMax(param, true)
+		case "len":
+			s.setMin(param, false)
+			s.setMax(param, false)
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, s.enumValue(v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "datetime":
+			if param == "2006-01-02" {
+				s.Format = "date"
+			}
+		}
+	}
+	return required
+}
+
+// kind is the schema's type, the non-null one for nullable types
+func (s *Schema) kind() string {
+	switch t := s.Type.(type) {
+	case string:
+		return t
+	case []string:
+		if len(t) > 0 {
+			return t[0]
+		}
+	}
+	return ""
+}
+
+func (s *Schema) setMin(param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.kind() {
+	case "string":
+		s.MinLength = intPtr(n, exclusive, 1)
+	case "array":
+		s.MinItems = intPtr(n, exclusive, 1)
+	case "integer", "number":
+		if exclusive {
+			s.ExclusiveMinimum = &n
+		} else {
+			s.Minimum = &n
+		}
+	}
+}
+
+func (s *Schema) setMax(param string, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.kind() {
+	case "string":
+		s.MaxLength = intPtr(n, exclusive, -1)
+	case "array":
+		s.MaxItems = intPtr(n, exclusive, -1)
+	case "integer", "number":
+		if exclusive {
+			s.ExclusiveMaximum = &n
+		} else {
+			s.Maximum = &n
+		}
+	}
+}
+
+// intPtr turns a length bound into an inclusive one
+func intPtr(n float64, exclusive bool, step int) *int {
+	v := int(n)
+	if exclusive {
+		v += step
+	}
+	return &v
+}
+
+// enumValue converts a oneof value to the schema's type
+func (s *Schema) enumValue(v string) any {
+	switch s.kind() {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
@@ -0,0 +1,103 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type raw string
+
+func (r raw) MarshalJSON() ([]byte, error) { return []byte(r), nil }
+
+type base struct {
+	ID int `json:"id"`
+}
+
+type node struct {
+	base
+	Name     string            `json:"name" doc:"Display name"`
+	Note     string            `json:"note,omitempty"`
+	Parent   *node             `json:"parent,omitempty"`
+	Children []node            `json:"children"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created"`
+	Body     raw               `json:"body"`
+	Count    *int              `json:"count"`
+	Big      int64             `json:"big,string"`
+	Hidden   string            `json:"-"`
+	internal string
+}
+
+func TestGenerator(t *testing.T) {
+	g := NewGenerator()
+	ref := g.Schema([]node{})
+	if ref.Type != "array" || ref.Items.Ref != "#/components/schemas/node" {
+		t.Fatalf("Expected an array of node references, got %+v", ref)
+	}
+
+	s := g.Schemas()["node"]
+	if s == nil {
+		t.Fatalf("Expected node to be a component")
+	}
+	if _, ok := s.Properties["id"]; !ok {
+		t.Errorf("Expected the embedded struct's fields to be flattened")
+	}
+	for _, name := range []string{"Hidden", "internal"} {
+		if _, ok := s.Properties[name]; ok {
+			t.Errorf("Expected %s to be left out", name)
+		}
+	}
+	want := []string{"id", "name", "children", "labels", "created", "body", "count", "big"}
+	if !reflect.DeepEqual(s.Required, want) {
+		t.Errorf("Expected required %v, got %v", want, s.Required)
+	}
+
+	tests := map[string]Schema{
+		"name":     {Type: "string", Description: "Display name"},
+		"parent":   {Ref: "#/components/schemas/node"},
+		"children": {Type: "array", Items: &Schema{Ref: "#/components/schemas/node"}},
+		"labels":   {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+		"created":  {Type: "string", Format: "date-time"},
+		"body":     {},
+		"count":    {Type: []string{"integer", "null"}, Format: "int64"},
+		"big":      {Type: "string"},
+	}
+	for name, want := range tests {
+		got, _ := json.Marshal(s.Properties[name])
+		exp, _ := json.Marshal(want)
+		if string(got) != string(exp) {
+			t.Errorf("%s: expected %s, got %s", name, exp, got)
+		}
+	}
+}
+
+func TestComponentNameCollision(t *testing.T) {
+	type Status struct{}
+	g := NewGenerator()
+	g.schemas["Status"] = &Schema{}
+	if got := g.Schema(Status{}).Ref; got != "#/components/schemas/openapi.Status" {
+		t.Errorf("Expected the package to tell the types apart, got %q", got)
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	type body struct {
+		Name  string   `json:"name,omitempty" binding:"required,min=2,max=10"`
+		Kind  string   `json:"kind" binding:"oneof=a b"`
+		Count int      `json:"count" binding:"gt=0,lte=5"`
+		Tags  []string `json:"tags" binding:"max=3,dive,email"`
+	}
+	g := NewGenerator()
+	g.Schema(body{})
+	s := g.Schemas()["body"]
+
+	got, _ := json.Marshal(s)
+	want := `{"type":"object","properties":{"count":{"type":"integer","format":"int64","maximum":5,"exclusiveMinimum":0},` +
+		`"kind":{"type":"string","enum":["a","b"]},"name":{"type":"string","minLength":2,"maxLength":10},` +
+		`"tags":{"type":"array","items":{"type":"string","format":"email"},"maxItems":3}},"required":["name","kind","count","tags"]}`
+	if string(got) != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled are dropped from memory
+const sweepInterval = time.Minute
+
+// MemoryStore keeps buckets in memory, so limits apply per process
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]memoryBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryBucket struct {
+	Bucket
+	full time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: map[string]memoryBucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket for key
+func (m *MemoryStore) Allow(ctx context.Context, key string, l Limit) (Result, error) {
+	if !l.Enabled() {
+		return Result{}, ErrDisabled
+	}
+	now := m.now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, b := range m.buckets {
+			if now.After(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+
+	b, res := Take(m.buckets[key].Bucket, l, now)
+	m.buckets[key] = memoryBucket{Bucket: b, full: now.Add(res.Reset)}
+	return res, nil
+}
+
+// Len returns the number of buckets held in memory
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
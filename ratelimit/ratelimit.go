@@ -0,0 +1,149 @@
+// Package ratelimit implements token bucket rate limiting with pluggable state.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// By selects what a limit counts requests against
+type By string
+
+const (
+	// ByIP gives every client address its own bucket
+	ByIP By = "ip"
+	// ByToken gives every API token or client certificate its own bucket
+	ByToken By = "token"
+	// ByRoute shares one bucket between all clients of a route
+	ByRoute By = "route"
+)
+
+// Limit allows Requests per Per, with bursts up to Requests
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	By       By
+}
+
+// Enabled reports whether the limit restricts anything
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// String formats the limit the way ParseLimit reads it
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return "off"
+	}
+	unit := l.Per.String()
+	switch l.Per {
+	case time.Second:
+		unit = "s"
+	case time.Minute:
+		unit = "m"
+	case time.Hour:
+		unit = "h"
+	}
+	s := fmt.Sprintf("%d/%s", l.Requests, unit)
+	if l.By != "" {
+		s += ":" + string(l.By)
+	}
+	return s
+}
+
+// ParseLimit reads a limit like "10/s", "100/m:token" or "5/30s:route". "off" disables the limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "" {
+		return Limit{}, nil
+	}
+
+	spec, by, hasBy := strings.Cut(s, ":")
+	n, per, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("rate limit %q must look like 10/m", s)
+	}
+
+	var l Limit
+	var err error
+	if l.Requests, err = strconv.Atoi(n); err != nil || l.Requests <= 0 {
+		return Limit{}, fmt.Errorf("rate limit %q needs a positive request count", s)
+	}
+	switch per {
+	case "s":
+		l.Per = time.Second
+	case "m":
+		l.Per = time.Minute
+	case "h":
+		l.Per = time.Hour
+	default:
+		if l.Per, err = time.ParseDuration(per); err != nil || l.Per <= 0 {
+			return Limit{}, fmt.Errorf("rate limit %q has an invalid period", s)
+		}
+	}
+
+	if hasBy {
+		switch By(by) {
+		case ByIP, ByToken, ByRoute:
+			l.By = By(by)
+		default:
+			return Limit{}, fmt.Errorf("rate limit %q must be keyed by ip, token or route", s)
+		}
+	}
+	return l, nil
+}
+
+// Bucket is the stored state of a token bucket
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Take refills a bucket for the time passed since it was last used and takes a
+// token from it if there is one. A zero bucket is treated as full.
+func Take(b Bucket, l Limit, now time.Time) (Bucket, Result) {
+	capacity := float64(l.Requests)
+	perToken := float64(l.Per) / capacity
+
+	if b.Updated.IsZero() {
+		b.Tokens = capacity
+	} else if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(capacity, b.Tokens+float64(elapsed)/perToken)
+	}
+	b.Updated = now
+
+	res := Result{Limit: l.Requests}
+	if b.Tokens >= 1 {
+		b.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - b.Tokens) * perToken)
+	}
+	res.Remaining = int(b.Tokens)
+	res.Reset = time.Duration((capacity - b.Tokens) * perToken)
+	return b, res
+}
+
+// Store keeps the buckets. Implementations must make Allow atomic per key.
+type Store interface {
+	Allow(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// ErrDisabled is returned when asking a disabled limit for a token
+var ErrDisabled = errors.New("rate limit is disabled")
@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"10/s", Limit{Requests: 10, Per: time.Second}},
+		{"100/m:token", Limit{Requests: 100, Per: time.Minute, By: ByToken}},
+		{"5/30s:route", Limit{Requests: 5, Per: 30 * time.Second, By: ByRoute}},
+		{"off", Limit{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		if err != nil {
+			t.Errorf("ParseLimit(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"10", "0/s", "ten/m", "10/fortnight", "10/m:user"} {
+		if _, err := ParseLimit(in); err == nil {
+			t.Errorf("Expected ParseLimit(%q) to fail", in)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryStore()
+	m.now = func() time.Time { return now }
+	l := Limit{Requests: 2, Per: time.Second}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if res, _ := m.Allow(ctx, "a", l); !res.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	res, _ := m.Allow(ctx, "a", l)
+	if res.Allowed || res.Remaining != 0 {
+		t.Errorf("Expected the bucket to be empty, got %+v", res)
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected to retry after 500ms, got %s", res.RetryAfter)
+	}
+	if res, _ := m.Allow(ctx, "b", l); !res.Allowed {
+		t.Errorf("Expected another key to have its own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if res, _ := m.Allow(ctx, "a", l); !res.Allowed {
+		t.Errorf("Expected a token to be refilled")
+	}
+
+	now = now.Add(2 * sweepInterval)
+	m.Allow(ctx, "c", l)
+	if m.Len() != 1 {
+		t.Errorf("Expected full buckets to be swept, %d left", m.Len())
+	}
+}
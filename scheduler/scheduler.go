@@ -0,0 +1,259 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const (
+	ResultOK    = "ok"
+	ResultError = "error"
+)
+
+var (
+	ErrUnknownJob   = errors.New("unknown job")
+	ErrJobRunning   = errors.New("job is already running")
+	ErrDuplicateJob = errors.New("job already exists")
+	ErrNotRunning   = errors.New("scheduler is not running")
+)
+
+// JobFunc does the work of a job, it should stop when ctx is done
+type JobFunc func(ctx context.Context) error
+
+// Job is a named function run on a schedule
+type Job struct {
+	Name string
+	// Spec is a cron expression, a @daily style alias or "@every <duration>"
+	Spec string
+	// Timeout bounds a single run, zero means no limit
+	Timeout time.Duration
+	// Jitter delays each run by a random duration up to this value
+	Jitter time.Duration
+	Func   JobFunc
+}
+
+// Status is the state of a job and the outcome of its last run
+type Status struct {
+	Name           string    `json:"name"`
+	Schedule       string    `json:"schedule"`
+	Running        bool      `json:"running"`
+	LastRun        time.Time `json:"lastRun,omitzero"`
+	LastDurationMs float64   `json:"lastDurationMs"`
+	LastResult     string    `json:"lastResult,omitempty"`
+	LastError      string    `json:"lastError,omitempty"`
+	NextRun        time.Time `json:"nextRun,omitzero"`
+	Runs           int       `json:"runs"`
+	Failures       int       `json:"failures"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	trigger  chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Scheduler runs jobs in the background until it is stopped
+type Scheduler struct {
+	mu      sync.Mutex
+	entries []*entry
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler without jobs
+func New() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a job. Jobs can't be added after Start.
+func (s *Scheduler) Add(j Job) error {
+	if j.Name == "" || j.Func == nil {
+		return errors.New("job needs a name and a function")
+	}
+	schedule, err := Parse(j.Spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", j.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return fmt.Errorf("job %s: scheduler already started", j.Name)
+	}
+	for _, e := range s.entries {
+		if e.job.Name == j.Name {
+			return fmt.Errorf("%w: %s", ErrDuplicateJob, j.Name)
+		}
+	}
+	s.entries = append(s.entries, &entry{
+		job:      j,
+		schedule: schedule,
+		trigger:  make(chan struct{}, 1),
+		status:   Status{Name: j.Name, Schedule: j.Spec},
+	})
+	return nil
+}
+
+// Start runs every job on its schedule
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, e := range s.entries {
+		s.wg.Add(1)
+		go func(e *entry) {
+			defer s.wg.Done()
+			e.loop(ctx)
+		}(e)
+	}
+}
+
+// Stop cancels running jobs and waits for them to return, or for ctx to expire
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.mu.Unlock()
+	if cancel == nil {
+		return nil
+	}
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("jobs still running: %w", ctx.Err())
+	}
+}
+
+// Jobs returns the status of every job in the order they were added
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	entries := make([]*entry, len(s.entries))
+	copy(entries, s.entries)
+	s.mu.Unlock()
+
+	jobs := make([]Status, len(entries))
+	for i, e := range entries {
+		e.mu.Lock()
+		jobs[i] = e.status
+		e.mu.Unlock()
+	}
+	return jobs
+}
+
+// Trigger runs a job now, outside of its schedule
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	started := s.cancel != nil
+	var found *entry
+	for _, e := range s.entries {
+		if e.job.Name == name {
+			found = e
+		}
+	}
+	s.mu.Unlock()
+
+	if found == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	if !started {
+		return ErrNotRunning
+	}
+	found.mu.Lock()
+	running := found.status.Running
+	found.mu.Unlock()
+	if running {
+		return fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+	select {
+	case found.trigger <- struct{}{}:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrJobRunning, name)
+	}
+}
+
+// loop runs the job until ctx is cancelled. Runs happen on this goroutine,
+// so a job never overlaps with itself and runs that fall due while it is
+// busy are dropped.
+func (e *entry) loop(ctx context.Context) {
+	for {
+		next := e.schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+		if e.job.Jitter > 0 {
+			next = next.Add(rand.N(e.job.Jitter))
+		}
+		e.mu.Lock()
+		e.status.NextRun = next
+		e.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-e.trigger:
+			timer.Stop()
+		}
+		e.run(ctx)
+	}
+}
+
+func (e *entry) run(ctx context.Context) {
+	e.mu.Lock()
+	e.status.Running = true
+	e.mu.Unlock()
+
+	if e.job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.job.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	err := runJob(ctx, e.job.Func)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.Running = false
+	e.status.LastRun = start
+	e.status.LastDurationMs = float64(time.Since(start).Microseconds()) / 1000
+	e.status.Runs++
+	e.status.LastResult = ResultOK
+	e.status.LastError = ""
+	if err != nil {
+		e.status.LastResult = ResultError
+		e.status.LastError = err.Error()
+		e.status.Failures++
+	}
+}
+
+// runJob runs fn and turns a panic into an error
+func runJob(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("job panicked: %v", v)
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,134 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2024, time.March, 15, 10, 30, 0, 0, time.UTC) // a Friday
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.March, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, time.March, 16, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.March, 15, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, time.March, 16, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * mon-wed", time.Date(2024, time.March, 18, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		// Day of month and day of week are OR'ed when both are restricted
+		{"0 0 20 * fri", time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC)},
+		{"@every 90s", base.Add(90 * time.Second)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(base); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@every soon"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", spec)
+		}
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	ran := make(chan struct{}, 1)
+	err := s.Add(Job{Name: "work", Spec: "@yearly", Func: func(ctx context.Context) error {
+		ran <- struct{}{}
+		<-release
+		return errors.New("broken")
+	}})
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add(Job{Name: "work", Spec: "@daily", Func: func(ctx context.Context) error { return nil }}); !errors.Is(err, ErrDuplicateJob) {
+		t.Errorf("Expected ErrDuplicateJob, got %v", err)
+	}
+	if err := s.Trigger("work"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Expected ErrNotRunning before Start, got %v", err)
+	}
+
+	s.Start()
+	defer s.Stop(context.Background())
+
+	if err := s.Trigger("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+	if err := s.Trigger("work"); err != nil {
+		t.Fatalf("Trigger failed: %v", err)
+	}
+	<-ran
+	if err := s.Trigger("work"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning while the job runs, got %v", err)
+	}
+	close(release)
+
+	waitFor(t, func() bool { return s.Jobs()[0].Runs == 1 })
+	st := s.Jobs()[0]
+	if st.LastResult != ResultError || st.LastError != "broken" || st.Failures != 1 {
+		t.Errorf("Unexpected status after failed run: %+v", st)
+	}
+	if st.NextRun.IsZero() {
+		t.Errorf("Expected the next run to be scheduled")
+	}
+}
+
+func TestTimeoutAndStop(t *testing.T) {
+	s := New()
+	started := make(chan struct{}, 1)
+	s.Add(Job{Name: "slow", Spec: "@every 1s", Timeout: 50 * time.Millisecond, Func: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Add(Job{Name: "forever", Spec: "@yearly", Func: func(ctx context.Context) error {
+		started <- struct{}{}
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	s.Start()
+
+	s.Trigger("slow")
+	waitFor(t, func() bool { return s.Jobs()[0].Runs == 1 })
+	if st := s.Jobs()[0]; st.LastError != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected the run to time out, got %+v", st)
+	}
+
+	s.Trigger("forever")
+	<-started
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.Stop(ctx); err != nil {
+		t.Fatalf("Stop should cancel running jobs: %v", err)
+	}
+	if st := s.Jobs()[1]; st.LastError != context.Canceled.Error() {
+		t.Errorf("Expected the job to see the cancellation, got %+v", st)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cache"
+)
+
+// CachePurgeResponse is the body of the cache purge endpoint
+type CachePurgeResponse struct {
+	Purged int `json:"purged" doc:"Number of cached responses removed"`
+}
+
+// CacheMiddleware serves GET and HEAD requests from the cache for the
+// policy's TTL. 200 responses get an ETag and Last-Modified, and are answered
+// with 304 Not Modified when the client already has them, cached or not.
+// Handlers keep a response out of the cache with Cache-Control: no-store.
+func CacheMiddleware(l *cache.LRU, route string, p cache.Policy) Middleware {
+	vary := strings.Join(p.Vary, ", ")
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+				return inner(c)
+			}
+			if vary != "" {
+				c.Writer.Header().Add("Vary", vary)
+			}
+
+			key := p.Key(route, c.Request.URL, c.Request.Header)
+			if e, ok := l.Get(key); ok {
+				c.Header("X-Cache", "HIT")
+				c.Header("Age", strconv.Itoa(int(time.Since(e.LastModified).Seconds())))
+				writeCached(c, e)
+				return nil
+			}
+
+			before := c.Writer.Header().Clone()
+			w := &cacheWriter{ResponseWriter: c.Writer}
+			c.Writer = w
+			// Restored before anything else writes, a panic is answered by the recovery middleware
+			defer func() { c.Writer = w.ResponseWriter }()
+
+			err := inner(c)
+			c.Writer = w.ResponseWriter
+			if err != nil {
+				if w.Written() {
+					c.Writer.WriteHeader(w.Status())
+					c.Writer.Write(w.body.Bytes())
+				}
+				return err
+			}
+
+			now := time.Now()
+			e := cache.Entry{
+				Status:       w.Status(),
+				Header:       http.Header{},
+				Body:         w.body.Bytes(),
+				LastModified: now,
+				Expires:      now.Add(p.TTL),
+			}
+			// Only the headers set by the handler are kept, the outer middleware sets its own on a hit
+			for k, v := range c.Writer.Header() {
+				if !slices.Equal(before[k], v) {
+					e.Header[k] = v
+				}
+			}
+			if e.Status == http.StatusOK {
+				e.ETag = cache.ETag(e.Body)
+				if !strings.Contains(e.Header.Get("Cache-Control"), "no-store") {
+					l.Set(key, e)
+				}
+			}
+			c.Header("X-Cache", "MISS")
+			writeCached(c, e)
+			return nil
+		}
+	}
+}
+
+// writeCached writes a response, or 304 Not Modified when the client's copy is current
+func writeCached(c *gin.Context, e cache.Entry) {
+	for k, v := range e.Header {
+		c.Writer.Header()[k] = v
+	}
+	if e.ETag != "" {
+		c.Header("ETag", e.ETag)
+		c.Header("Last-Modified", e.LastModified.UTC().Format(http.TimeFormat))
+		if cache.NotModified(c.Request, e.ETag, e.LastModified) {
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Length")
+			c.Status(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+	}
+	c.Status(e.Status)
+	c.Writer.Write(e.Body)
+}
+
+// cachePurgeHandler empties the response cache, or only the entries of the route given in ?route=
+func (srv *Server) cachePurgeHandler(c *gin.Context) error {
+	n := 0
+	if srv.responseCache != nil {
+		prefix := ""
+		if route := c.Query("route"); route != "" {
+			prefix = cache.RoutePrefix(route)
+		}
+		n = srv.responseCache.Purge(prefix)
+	}
+	c.JSON(http.StatusOK, CachePurgeResponse{Purged: n})
+	return nil
+}
+
+// cacheWriter holds the response back until the handler is done, so that
+// the ETag can be computed from the whole body
+type cacheWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *cacheWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.WriteString(s)
+}
+
+func (w *cacheWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *cacheWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *cacheWriter) Written() bool {
+	return w.status != 0
+}
+
+// Flush is a no-op, routes that stream shouldn't be cached
+func (w *cacheWriter) Flush() {}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cache"
+)
+
+func TestCacheMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	report := func(c *gin.Context) error {
+		calls++
+		if c.Query("open") != "" {
+			c.Header("Cache-Control", "no-store")
+		}
+		c.Header("Content-Type", "text/plain")
+		c.String(http.StatusOK, "%s report %d", c.GetHeader("Accept"), calls)
+		return nil
+	}
+	l := cache.New(1 << 20)
+	policy := cache.Policy{TTL: time.Minute, Vary: []string{"Accept"}}
+	router := gin.New()
+	router.GET("/report", WrapHandler(CacheMiddleware(l, "Report", policy)(report)))
+
+	call := func(url string, header http.Header) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", url, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := call("/report?a=1&b=2", nil)
+	if first.Code != http.StatusOK || first.Header().Get("X-Cache") != "MISS" {
+		t.Fatalf("Expected a miss, got %d %q", first.Code, first.Header().Get("X-Cache"))
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" || first.Header().Get("Last-Modified") == "" {
+		t.Fatalf("Expected ETag and Last-Modified, got %v", first.Header())
+	}
+
+	hit := call("/report?b=2&a=1", nil)
+	if hit.Header().Get("X-Cache") != "HIT" || hit.Body.String() != first.Body.String() {
+		t.Errorf("Expected the cached response, got %q %q", hit.Header().Get("X-Cache"), hit.Body.String())
+	}
+	if hit.Header().Get("ETag") != etag || hit.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("Expected the cached headers, got %v", hit.Header())
+	}
+
+	if w := call("/report?a=1&b=2", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected 304 for a current ETag, got %d %q", w.Code, w.Body.String())
+	}
+	if w := call("/report?a=1&b=2", http.Header{"If-Modified-Since": {first.Header().Get("Last-Modified")}}); w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a current date, got %d", w.Code)
+	}
+
+	if w := call("/report?a=1&b=2", http.Header{"Accept": {"application/json"}}); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected Accept to select another entry")
+	}
+
+	call("/report?open=1", nil)
+	if w := call("/report?open=1", nil); w.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("Expected no-store responses not to be cached")
+	}
+
+	if calls != 4 {
+		t.Errorf("Expected the handler to run 4 times, ran %d times", calls)
+	}
+}
+
+func TestCacheMiddlewareSkipsErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	calls := 0
+	failing := func(c *gin.Context) error {
+		calls++
+		return fmt.Errorf("backend down")
+	}
+	router := gin.New()
+	router.GET("/report", WrapHandler(CacheMiddleware(cache.New(1<<20), "Report", cache.Policy{TTL: time.Minute})(failing)))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/report", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("Expected the error to be answered, got %d", w.Code)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("Expected errors not to be cached, the handler ran %d times", calls)
+	}
+}
+
+func TestCachePurgeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := New()
+	srv.responseCache = cache.New(1 << 20)
+	expires := time.Now().Add(time.Minute)
+	srv.responseCache.Set(cache.RoutePrefix("GetLogs")+"/a", cache.Entry{Expires: expires})
+	srv.responseCache.Set(cache.RoutePrefix("GetLogs")+"/b", cache.Entry{Expires: expires})
+	srv.responseCache.Set(cache.RoutePrefix("HealthCheck")+"/", cache.Entry{Expires: expires})
+
+	router := gin.New()
+	router.DELETE("/admin/cache", WrapHandler(srv.cachePurgeHandler))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/cache?route=GetLogs", nil))
+	if w.Body.String() != `{"purged":2}` {
+		t.Errorf("Expected 2 entries to be purged, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("DELETE", "/admin/cache", nil))
+	if w.Body.String() != `{"purged":1}` || srv.responseCache.Len() != 0 {
+		t.Errorf("Expected the rest to be purged, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"time"
+
+	"github.com/johansundell/template-service/fmsodata"
+	"github.com/johansundell/template-service/health"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/tracing"
+)
+
+// newHealthRegistry registers the checks behind /readyz
+func (srv *Server) newHealthRegistry(storage *store.Storage, logWriter *store.LogWriter) *health.Registry {
+	timeout := time.Duration(srv.settings.HealthTimeout) * time.Second
+	cacheTTL := time.Duration(srv.settings.HealthCacheTTL) * time.Second
+	if cacheTTL == 0 {
+		cacheTTL = -1
+	}
+
+	reg := health.NewRegistry()
+	reg.Register(health.Check{
+		Name:     "database",
+		Critical: true,
+		Timeout:  timeout,
+		CacheTTL: cacheTTL,
+		Func:     storage.PingContext,
+	})
+	reg.Register(health.Check{
+		Name:     "disk",
+		Critical: true,
+		Timeout:  timeout,
+		CacheTTL: cacheTTL,
+		Func:     health.DiskSpaceCheck(".", uint64(srv.settings.MinFreeDiskMB)<<20),
+	})
+	reg.Register(health.Check{
+		Name:     "logQueue",
+		Timeout:  timeout,
+		CacheTTL: cacheTTL,
+		Func:     health.QueueDepthCheck(logWriter.Len, logWriter.Cap()),
+	})
+
+	if srv.settings.FileMakerSettings.Host != "" {
+		reg.Register(health.Check{
+			Name:     "filemaker",
+			Timeout:  timeout,
+			CacheTTL: cacheTTL,
+			Func:     srv.newFileMakerClient().Ping,
+		})
+	}
+	return reg
+}
+
+func (srv *Server) newFileMakerClient() *fmsodata.Client {
+	s := srv.settings.FileMakerSettings
+	return fmsodata.NewClient(fmsodata.ClientConfig{
+		Host:     s.Host,
+		Database: s.Database,
+		Username: s.Username,
+		Password: s.Password,
+		Timeout:  time.Duration(srv.settings.Timeout) * time.Second,
+		// Count and time the calls for /metrics
+		Transport: srv.metrics.Transport(tracing.Transport(nil)),
+	})
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kardianos/service"
+)
+
+// command is a subcommand of the binary
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(args []string) error
+}
+
+func (srv *Server) commands() []command {
+	return []command{
+		{"serve", "serve [-envfile file]", "Run the web service (default).", srv.runServe},
+		{"service", "service install|uninstall|start|stop|restart|status [flags]", "Control the system service.", srv.runService},
+		{"db", "db migrate|backup|vacuum [flags]", "Maintain the database.", srv.runDB},
+		{"logs", "logs query|export|prune|migrate [flags]", "Work with the request logs.", srv.runLogs},
+		{"config", "config check|print [-envfile file]", "Validate or show the settings.", srv.runConfig},
+		{"maintenance", "maintenance on|off|status [flags]", "Switch maintenance mode of a running instance.", srv.runMaintenance},
+		{"healthcheck", "healthcheck [-envfile file] [-url url]", "Probe /readyz of a running instance, for Docker HEALTHCHECK.", srv.runHealthcheck},
+		{"version", "version", "Print the version.", srv.runVersion},
+	}
+}
+
+// run dispatches to a subcommand. Flags without a subcommand, like the
+// original -service install, start the serve command.
+func (srv *Server) run(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return srv.runServe(args)
+	}
+	if args[0] == "help" {
+		srv.printUsage()
+		return nil
+	}
+	for _, c := range srv.commands() {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	srv.printUsage()
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func (srv *Server) printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [arguments]\n\nCommands:\n", srv.name)
+	for _, c := range srv.commands() {
+		fmt.Fprintf(os.Stderr, "  %-60s %s\n", c.usage, c.summary)
+	}
+}
+
+// newFlagSet creates the flags for a subcommand, with -envfile shared by all of them
+func (srv *Server) newFlagSet(name string, envFile *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(srv.name+" "+name, flag.ContinueOnError)
+	fs.StringVar(envFile, "envfile", "", "Load settings from this file instead of .env.")
+	return fs
+}
+
+// subcommand splits "action [flags]" arguments
+func subcommand(args []string, actions ...string) (string, []string, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return "", nil, fmt.Errorf("missing action, want one of %s", strings.Join(actions, ", "))
+	}
+	if !slices.Contains(actions, args[0]) {
+		return "", nil, fmt.Errorf("unknown action %q, want one of %s", args[0], strings.Join(actions, ", "))
+	}
+	return args[0], args[1:], nil
+}
+
+func (srv *Server) runServe(args []string) error {
+	var install installOptions
+	fs := srv.newFlagSet("serve", &install.envFile)
+	svcFlag := fs.String("service", "", "Control the system service. Kept for compatibility, use the service command.")
+	install.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *svcFlag != "" {
+		return srv.serviceAction(*svcFlag, &install)
+	}
+
+	if err := srv.loadSettings(install.envFile); err != nil {
+		return err
+	}
+	s, err := srv.newService(&install)
+	if err != nil {
+		return err
+	}
+	if err := s.Run(); err != nil {
+		srv.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+func (srv *Server) runService(args []string) error {
+	action, rest, err := subcommand(args, "install", "uninstall", "start", "stop", "restart", "status")
+	if err != nil {
+		return err
+	}
+	var install installOptions
+	fs := srv.newFlagSet("service "+action, &install.envFile)
+	install.register(fs)
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	return srv.serviceAction(action, &install)
+}
+
+// serviceAction installs, controls or reports on the system service
+func (srv *Server) serviceAction(action string, install *installOptions) error {
+	if err := srv.loadSettings(install.envFile); err != nil {
+		return err
+	}
+
+	if action == "install" {
+		// Validate before the unit file is written
+		if err := install.validate(); err != nil {
+			return err
+		}
+		if err := validateSettings(srv.settings); err != nil {
+			return err
+		}
+	}
+
+	s, err := srv.newService(install)
+	if err != nil {
+		return err
+	}
+
+	if action == "status" {
+		return srv.printStatus(s)
+	}
+	if action == "uninstall" && srv.usesSocketActivation() {
+		if err := srv.removeSocketUnits(); err != nil {
+			return err
+		}
+	}
+	if err := service.Control(s, action); err != nil {
+		log.Printf("Valid actions: %q\n", append(service.ControlAction[:], "status"))
+		return err
+	}
+	if action == "install" && srv.usesSocketActivation() {
+		return srv.installSocketUnits()
+	}
+	return nil
+}
+
+// newService creates the kardianos service and sets up the logger
+func (srv *Server) newService(install *installOptions) (service.Service, error) {
+	prg := &program{server: srv}
+	s, err := service.New(prg, srv.serviceConfig(install))
+	if err != nil {
+		return nil, err
+	}
+	errs := make(chan error, 5)
+	srv.logger, err = s.Logger(errs)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			err := <-errs
+			if err != nil {
+				log.Print(err)
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (srv *Server) runVersion(args []string) error {
+	if len(args) > 0 {
+		return errors.New("version takes no arguments")
+	}
+	fmt.Printf("%s %s (%s, %s/%s)\n", srv.name, srv.version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
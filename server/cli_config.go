@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const redacted = "********"
+
+func (srv *Server) runConfig(args []string) error {
+	action, rest, err := subcommand(args, "check", "print")
+	if err != nil {
+		return err
+	}
+
+	var envFile string
+	fs := srv.newFlagSet("config "+action, &envFile)
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	if err := srv.loadSettings(envFile); err != nil {
+		return err
+	}
+
+	switch action {
+	case "check":
+		if err := validateSettings(srv.settings); err != nil {
+			return err
+		}
+		fmt.Println("Configuration is valid")
+
+	case "print":
+		// Secrets are masked so the output can be pasted into tickets, webhook
+		// URLs usually carry a token
+		s := srv.settings
+		for _, secret := range []*string{&s.AuthToken, &s.MySqlSettings.Password, &s.FileMakerSettings.Password, &s.PanicWebhookURL} {
+			if *secret != "" {
+				*secret = redacted
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/johansundell/template-service/store"
+)
+
+func (srv *Server) runDB(args []string) error {
+	action, rest, err := subcommand(args, "migrate", "backup", "vacuum")
+	if err != nil {
+		return err
+	}
+
+	var envFile string
+	fs := srv.newFlagSet("db "+action, &envFile)
+	output := fs.String("o", "", "Backup file to write (backup only).")
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	if err := srv.loadSettings(envFile); err != nil {
+		return err
+	}
+
+	db, err := srv.openDatabase(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch action {
+	case "migrate":
+		applied, err := store.Migrate(db)
+		for _, v := range applied {
+			fmt.Println("Applied migration", v)
+		}
+		if err != nil {
+			return err
+		}
+		version, err := store.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Schema is at version", version)
+		return nil
+
+	case "backup":
+		if *output == "" {
+			return errors.New("backup needs -o <file>")
+		}
+		if err := store.NewStorage(db).Backup(*output); err != nil {
+			if errors.Is(err, store.ErrNotSupported) {
+				return errors.New("backup is only supported for SQLite, use mysqldump for MySQL")
+			}
+			return err
+		}
+		fmt.Println("Backup written to", *output)
+		return nil
+
+	case "vacuum":
+		if err := store.NewStorage(db).Vacuum(); err != nil {
+			return err
+		}
+		fmt.Println("Database vacuumed")
+		return nil
+	}
+	return nil
+}
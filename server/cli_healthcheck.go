@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/johansundell/template-service/listener"
+)
+
+// runHealthcheck probes /readyz of a running instance and fails unless it
+// answers 200. It needs no shell or curl, so it works in distroless images.
+func (srv *Server) runHealthcheck(args []string) error {
+	var envFile string
+	fs := srv.newFlagSet("healthcheck", &envFile)
+	url := fs.String("url", "", "URL to probe, defaults to /readyz on the admin or public listener.")
+	insecure := fs.Bool("insecure", false, "Don't verify the certificate of -url.")
+	timeout := fs.Duration("timeout", 5*time.Second, "How long to wait for an answer.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := srv.loadSettings(envFile); err != nil {
+		return err
+	}
+
+	client, target := srv.adminClient("", "/readyz", *timeout, false)
+	if *url != "" {
+		client, target = srv.adminClient(*url, "", *timeout, *insecure)
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", target, resp.Status)
+	}
+	fmt.Println(target, resp.Status)
+	return nil
+}
+
+// adminClient returns a client for the admin listener of the running
+// instance, or the public one when there is none, and the URL of path on it.
+// A base URL given by the user replaces the listener, its certificate is
+// verified unless insecure is set.
+func (srv *Server) adminClient(base, path string, timeout time.Duration, insecure bool) (*http.Client, string) {
+	if base != "" {
+		transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure}}
+		return &http.Client{Transport: transport, Timeout: timeout}, strings.TrimSuffix(base, "/") + path
+	}
+	transport := &http.Transport{
+		// The client only talks to its own listener over loopback or a unix
+		// socket, the certificate is usually issued for a public name
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	addr, useTLS := srv.settings.Port, tlsConfig(srv.settings).Enabled()
+	if srv.settings.AdminPort != "" {
+		addr, useTLS = srv.settings.AdminPort, srv.settings.AdminTLS
+	}
+	if listener.IsUnix(addr) {
+		socket := strings.TrimPrefix(addr, listener.UnixPrefix)
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, localURL(addr, useTLS, path)
+}
+
+// probeURL builds the /readyz URL for a listen address
+func probeURL(addr string, useTLS bool) string {
+	return localURL(addr, useTLS, "/readyz")
+}
+
+// localURL builds the URL of path on a listen address, using loopback for wildcard hosts
+func localURL(addr string, useTLS bool, path string) string {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	if listener.IsUnix(addr) {
+		return scheme + "://localhost" + path
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return scheme + "://" + addr + path
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port) + path
+}
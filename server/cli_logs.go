@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/johansundell/template-service/fmsodata"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+)
+
+const dateLayout = "2006-01-02"
+
+func (srv *Server) runLogs(args []string) error {
+	action, rest, err := subcommand(args, "query", "export", "prune", "migrate")
+	if err != nil {
+		return err
+	}
+
+	var envFile string
+	fs := srv.newFlagSet("logs "+action, &envFile)
+	today := time.Now().Format(dateLayout)
+	from := fs.String("from", today, "First day to include, YYYY-MM-DD.")
+	to := fs.String("to", today, "Last day to include, YYYY-MM-DD.")
+	format := fs.String("format", "json", "Export format, json or csv.")
+	output := fs.String("o", "", "Export file, defaults to stdout.")
+	olderThan := fs.Int("older-than", 0, "Prune logs older than this many days.")
+	table := fs.String("table", "Logs", "FileMaker table to migrate to.")
+	keepTable := fs.Bool("keep-table", false, "Add to the existing FileMaker table instead of dropping and creating it.")
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	if err := srv.loadSettings(envFile); err != nil {
+		return err
+	}
+
+	// Like db backup and vacuum the schema is used as it is, serve and db
+	// migrate are the only commands that migrate it
+	db, err := srv.openDatabase(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	storage := store.NewStorage(db)
+
+	if action == "prune" {
+		if *olderThan <= 0 {
+			return errors.New("prune needs -older-than <days>")
+		}
+		removed, err := storage.PruneLogs(time.Now().AddDate(0, 0, -*olderThan))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d logs\n", removed)
+		return nil
+	}
+
+	logs, err := queryLogs(storage, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "query":
+		return writeLogsJSON(os.Stdout, logs)
+
+	case "export":
+		w := io.Writer(os.Stdout)
+		if *output != "" {
+			f, err := os.Create(*output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		switch *format {
+		case "json":
+			return writeLogsJSON(w, logs)
+		case "csv":
+			return writeLogsCSV(w, logs)
+		}
+		return fmt.Errorf("unknown export format %q, want json or csv", *format)
+
+	case "migrate":
+		return srv.migrateLogsToFileMaker(logs, *table, !*keepTable)
+	}
+	return nil
+}
+
+// queryLogs returns the logs for whole days, like the /logs endpoint
+func queryLogs(storage *store.Storage, fromStr, toStr string) ([]types.UsageLog, error) {
+	from, err := time.Parse(dateLayout, fromStr)
+	if err != nil {
+		return nil, errors.New("wrong date format in -from")
+	}
+	to, err := time.Parse(dateLayout, toStr)
+	if err != nil {
+		return nil, errors.New("wrong date format in -to")
+	}
+	return storage.GetLogs(from, to.Add(24*time.Hour).Add(-1*time.Second))
+}
+
+func writeLogsJSON(w io.Writer, logs []types.UsageLog) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(logs)
+}
+
+func writeLogsCSV(w io.Writer, logs []types.UsageLog) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "status", "method", "error", "endpoint", "created_at", "client_ip", "client_identity", "request_id", "trace_id", "timeout_ms", "timed_out", "stack", "request", "response"})
+	for _, l := range logs {
+		cw.Write([]string{
+			strconv.Itoa(l.ID),
+			strconv.Itoa(l.Status),
+			l.Method,
+			l.Error,
+			l.Endpoint,
+			l.CreatedAt.Format(time.RFC3339),
+			l.ClientIP,
+			l.ClientIdentity,
+			l.RequestID,
+			l.TraceID,
+			strconv.FormatInt(l.TimeoutMs, 10),
+			strconv.FormatBool(l.TimedOut),
+			l.Stack,
+			string(l.Request),
+			string(l.Response),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// migrateLogsToFileMaker copies request logs into a FileMaker table through
+// OData. With create the table is dropped and created first, as the old
+// cmd/migrate_logs tool always did.
+func (srv *Server) migrateLogsToFileMaker(logs []types.UsageLog, tableName string, create bool) error {
+	if srv.settings.FileMakerSettings.Host == "" {
+		return errors.New("FMS_HOST is not set")
+	}
+	client := srv.newFileMakerClient()
+	ctx := context.Background()
+
+	if create {
+		fmt.Println("Creating table...")
+		tableDef := fmsodata.TableDefinition{
+			TableName: tableName,
+			Fields: []fmsodata.FieldDefinition{
+				{Name: "ID", Type: "NUMERIC", Primary: true, Unique: true},
+				{Name: "Status", Type: "NUMERIC"},
+				{Name: "Method", Type: "VARCHAR"},
+				{Name: "Error", Type: "VARCHAR"},
+				{Name: "Endpoint", Type: "VARCHAR"},
+				{Name: "CreatedAt", Type: "TIMESTAMP"},
+				{Name: "Response", Type: "VARCHAR"},
+				{Name: "Request", Type: "VARCHAR"},
+			},
+		}
+		_ = client.DeleteTable(ctx, tableName)
+		if err := client.CreateTable(ctx, tableDef); err != nil {
+			return fmt.Errorf("create table: %w", err)
+		}
+	}
+
+	fmt.Printf("Uploading %d logs...\n", len(logs))
+	failed := 0
+	for _, entry := range logs {
+		record := map[string]interface{}{
+			"ID":        entry.ID,
+			"Status":    entry.Status,
+			"Method":    entry.Method,
+			"Error":     entry.Error,
+			"Endpoint":  entry.Endpoint,
+			"CreatedAt": entry.CreatedAt.Format(time.RFC3339),
+			"Response":  string(entry.Response),
+			"Request":   string(entry.Request),
+		}
+		if _, err := client.CreateRecord(ctx, tableName, record); err != nil {
+			fmt.Printf("Failed to create record for log ID %d: %v\n", entry.ID, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d logs failed to upload", failed, len(logs))
+	}
+	fmt.Println("Migration complete.")
+	return nil
+}
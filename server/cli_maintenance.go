@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/maintenance"
+)
+
+// runMaintenance switches maintenance mode of the running instance through
+// its admin endpoint, so that the toggle lands in the audit trail
+func (srv *Server) runMaintenance(args []string) error {
+	action, rest, err := subcommand(args, "on", "off", "status")
+	if err != nil {
+		return err
+	}
+
+	var envFile string
+	fs := srv.newFlagSet("maintenance "+action, &envFile)
+	base := fs.String("url", "", "Base URL of the admin listener, defaults to the one in the settings.")
+	insecure := fs.Bool("insecure", false, "Don't verify the certificate of -url.")
+	reason := fs.String("reason", "", "Reason shown to clients (on only).")
+	retryAfter := fs.Duration("retry-after", 0, "How long clients are told to wait, defaults to MAINTENANCE_RETRY_AFTER (on only).")
+	timeout := fs.Duration("timeout", 10*time.Second, "How long to wait for an answer.")
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	if err := srv.loadSettings(envFile); err != nil {
+		return err
+	}
+
+	client, target := srv.adminClient(*base, "/admin/maintenance", *timeout, *insecure)
+	method := http.MethodGet
+	var body io.Reader
+	if action != "status" {
+		enabled := action == "on"
+		data, err := json.Marshal(handlers.MaintenanceRequest{
+			Enabled:    &enabled,
+			Reason:     *reason,
+			RetryAfter: int(retryAfter.Seconds()),
+			Label:      cliLabel(),
+		})
+		if err != nil {
+			return err
+		}
+		method, body = http.MethodPut, bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if srv.settings.AuthToken != "" {
+		req.Header.Set("Authorization", "Bearer "+srv.settings.AuthToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s answered %s: %s", target, resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var state maintenance.State
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return err
+	}
+	if !state.Enabled {
+		fmt.Println("Maintenance mode is off")
+		return nil
+	}
+	fmt.Printf("Maintenance mode is on since %s (%s)", state.Since.Format(time.RFC3339), state.Source)
+	if state.Reason != "" {
+		fmt.Printf(": %s", state.Reason)
+	}
+	fmt.Println()
+	return nil
+}
+
+// cliLabel names the person running the CLI in the audit trail
+func cliLabel() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name + " (cli)"
+}
@@ -0,0 +1,38 @@
+package server
+
+import "testing"
+
+func TestProbeURL(t *testing.T) {
+	tests := []struct {
+		addr   string
+		useTLS bool
+		want   string
+	}{
+		{":8080", false, "http://127.0.0.1:8080/readyz"},
+		{"0.0.0.0:8443", true, "https://127.0.0.1:8443/readyz"},
+		{"10.0.0.5:9090", false, "http://10.0.0.5:9090/readyz"},
+		{"unix:/run/app.sock", false, "http://localhost/readyz"},
+	}
+	for _, tt := range tests {
+		if got := probeURL(tt.addr, tt.useTLS); got != tt.want {
+			t.Errorf("probeURL(%q, %v) = %q, want %q", tt.addr, tt.useTLS, got, tt.want)
+		}
+	}
+}
+
+func TestSubcommand(t *testing.T) {
+	action, rest, err := subcommand([]string{"backup", "-o", "copy.db"}, "migrate", "backup")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if action != "backup" || len(rest) != 2 {
+		t.Errorf("Expected backup with 2 arguments, got %s %v", action, rest)
+	}
+
+	if _, _, err := subcommand([]string{"-o", "copy.db"}, "migrate", "backup"); err == nil {
+		t.Errorf("Expected error for missing action")
+	}
+	if _, _, err := subcommand([]string{"restore"}, "migrate", "backup"); err == nil {
+		t.Errorf("Expected error for unknown action")
+	}
+}
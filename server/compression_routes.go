@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/compression"
+	"github.com/johansundell/template-service/types"
+)
+
+// compressionConfig builds the compression settings from COMPRESSION_*
+func compressionConfig(s types.AppSettings) compression.Config {
+	contentTypes := compression.DefaultContentTypes
+	if s.CompressionTypes != "" {
+		contentTypes = nil
+		for _, t := range strings.Split(s.CompressionTypes, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				contentTypes = append(contentTypes, t)
+			}
+		}
+	}
+	return compression.Config{
+		MinSize:      s.CompressionMinSize,
+		ContentTypes: contentTypes,
+	}
+}
+
+// CompressionMiddleware compresses responses with the best encoding the client
+// accepts. It wraps the writer before the request logger does, so the logged
+// body stays uncompressed.
+func CompressionMiddleware(cfg compression.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := compression.Negotiate(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == "HEAD" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, cw: compression.NewWriter(c.Writer, encoding, cfg)}
+		c.Writer = w
+		// Also when a handler panics, so the encoder is returned to its pool
+		// and PanicRecovery writes its response to the plain writer
+		defer func() {
+			if err := w.cw.Close(); err != nil {
+				c.Error(err)
+			}
+			c.Writer = w.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// compressWriter routes gin's writes through a compression.Writer
+type compressWriter struct {
+	gin.ResponseWriter
+	cw *compression.Writer
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	w.cw.WriteHeader(code)
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.cw.WriteHeaderNow()
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	return w.cw.Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.cw.Write([]byte(s))
+}
+
+func (w *compressWriter) Status() int {
+	return w.cw.Status()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.cw.Written()
+}
+
+func (w *compressWriter) Flush() {
+	w.cw.Flush()
+}
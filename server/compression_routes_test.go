@@ -0,0 +1,83 @@
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/compression"
+	"github.com/johansundell/template-service/metrics"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+	body := `[` + strings.Repeat(`{"status":200},`, 100) + `{"status":200}]`
+
+	router := gin.New()
+	router.Use(CompressionMiddleware(compression.Config{MinSize: 1024, ContentTypes: compression.DefaultContentTypes}))
+	router.GET("/logs", WrapHandler(LoggerMiddleware(sink)(func(c *gin.Context) error {
+		c.Data(http.StatusOK, "application/json", []byte(body))
+		return nil
+	})))
+	router.GET("/missing", WrapHandler(LoggerMiddleware(sink)(func(c *gin.Context) error {
+		c.JSON(http.StatusNotFound, gin.H{"error": "missing"})
+		return nil
+	})))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/logs", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected a gzipped response, got %q", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Invalid gzip body: %v", err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("Body changed by compression")
+	}
+	if string(saved.Response) != body {
+		t.Errorf("Expected the request log to keep the uncompressed body, got %q", saved.Response)
+	}
+
+	// The status of a small response that is still buffered must reach the log
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/missing", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound || saved.Status != http.StatusNotFound {
+		t.Errorf("Expected 404 in the response and the log, got %d and %d", w.Code, saved.Status)
+	}
+}
+
+func TestCompressionMiddlewarePanic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(PanicRecovery(metrics.New("test"), nil, map[string]string{}))
+	router.Use(CompressionMiddleware(compression.Config{MinSize: 1024, ContentTypes: compression.DefaultContentTypes}))
+	router.GET("/boom", func(c *gin.Context) { panic("boom") })
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/boom", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"status":500`) {
+		t.Errorf("Expected a JSON 500, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/johansundell/template-service/listener"
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/scheduler"
+	"github.com/johansundell/template-service/tlsutil"
+	"github.com/johansundell/template-service/tracing"
+	"github.com/johansundell/template-service/types"
+	"github.com/joho/godotenv"
+)
+
+// loadSettings reads the settings from the environment. Values from envFile,
+// or from .env when envFile is empty, are added to the environment first.
+func (srv *Server) loadSettings(envFile string) error {
+	if envFile != "" {
+		if err := godotenv.Load(envFile); err != nil {
+			return fmt.Errorf("load %s: %w", envFile, err)
+		}
+	} else if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using default/environment values")
+	}
+
+	var settings types.AppSettings
+
+	settings.Debug, _ = strconv.ParseBool(os.Getenv("DEBUG"))
+	settings.Port = os.Getenv("PORT")
+	if settings.Port == "" {
+		settings.Port = ":8080"
+	}
+	settings.AdminPort = os.Getenv("ADMIN_PORT")
+	settings.AdminTLS, _ = strconv.ParseBool(os.Getenv("ADMIN_TLS"))
+	settings.UnixSocketMode = os.Getenv("UNIX_SOCKET_MODE")
+	if settings.UnixSocketMode == "" {
+		settings.UnixSocketMode = "0660"
+	}
+	settings.UnixSocketGroup = os.Getenv("UNIX_SOCKET_GROUP")
+	settings.SystemdSocket = os.Getenv("SYSTEMD_SOCKET")
+	settings.AdminSystemdSocket = os.Getenv("ADMIN_SYSTEMD_SOCKET")
+	settings.UseFileSystem, _ = strconv.ParseBool(os.Getenv("USE_FILE_SYSTEM"))
+
+	timeoutStr := os.Getenv("TIMEOUT")
+	if timeoutStr != "" {
+		settings.Timeout, _ = strconv.Atoi(timeoutStr)
+	} else {
+		settings.Timeout = 15
+	}
+
+	settings.ShutdownTimeout = envInt("SHUTDOWN_TIMEOUT", 10)
+	settings.LogQueueSize = envInt("LOG_QUEUE_SIZE", 1000)
+	settings.HealthTimeout = envInt("HEALTH_TIMEOUT", 2)
+	settings.HealthCacheTTL = envInt("HEALTH_CACHE_TTL", 5)
+	settings.MinFreeDiskMB = envInt("MIN_FREE_DISK_MB", 100)
+	settings.LogRetentionDays = envInt("LOG_RETENTION_DAYS", 0)
+	settings.LogPruneSchedule = os.Getenv("LOG_PRUNE_SCHEDULE")
+	if settings.LogPruneSchedule == "" {
+		settings.LogPruneSchedule = "@daily"
+	}
+	settings.JobTimeout = envInt("JOB_TIMEOUT", 300)
+	settings.JobJitter = envInt("JOB_JITTER", 0)
+	settings.Compression, _ = strconv.ParseBool(envString("COMPRESSION", "true"))
+	settings.CompressionMinSize = envInt("COMPRESSION_MIN_SIZE", 1024)
+	settings.CompressionTypes = os.Getenv("COMPRESSION_TYPES")
+	settings.PanicWebhookURL = os.Getenv("PANIC_WEBHOOK_URL")
+	settings.MaxBodyBytes = int64(envInt("MAX_BODY_BYTES", 1<<20))
+	settings.IdempotencyTTL = envInt("IDEMPOTENCY_TTL", 24)
+	settings.CacheMaxBytes = int64(envInt("CACHE_MAX_BYTES", 32<<20))
+	settings.MaintenanceFile = os.Getenv("MAINTENANCE_FILE")
+	settings.MaintenanceRetryAfter = envInt("MAINTENANCE_RETRY_AFTER", 300)
+	settings.MaintenanceAllow = os.Getenv("MAINTENANCE_ALLOW")
+	settings.RateLimits = os.Getenv("RATE_LIMITS")
+	settings.RateLimitBackend = os.Getenv("RATE_LIMIT_BACKEND")
+	settings.RateLimitPreAuth = os.Getenv("RATE_LIMIT_PRE_AUTH")
+	if settings.RateLimitBackend == "" {
+		settings.RateLimitBackend = "memory"
+	}
+
+	settings.UseMySQL, _ = strconv.ParseBool(os.Getenv("USE_MYSQL"))
+	if !settings.UseMySQL {
+		settings.UseSqlite = true
+	}
+	settings.AuthToken = os.Getenv("AUTH_TOKEN")
+
+	settings.MySqlSettings.Username = os.Getenv("MYSQL_USERNAME")
+	settings.MySqlSettings.Password = os.Getenv("MYSQL_PASSWORD")
+	settings.MySqlSettings.Host = os.Getenv("MYSQL_HOST")
+	settings.MySqlSettings.Port = os.Getenv("MYSQL_PORT")
+	settings.MySqlSettings.Database = os.Getenv("MYSQL_DATABASE")
+
+	settings.TLSSettings.CertFile = os.Getenv("TLS_CERT_FILE")
+	settings.TLSSettings.KeyFile = os.Getenv("TLS_KEY_FILE")
+	settings.TLSSettings.MinVersion = os.Getenv("TLS_MIN_VERSION")
+	settings.TLSSettings.CipherPolicy = os.Getenv("TLS_CIPHERS")
+	settings.TLSSettings.ClientCAFile = os.Getenv("TLS_CLIENT_CA_FILE")
+	settings.TLSSettings.ClientAuth = os.Getenv("TLS_CLIENT_AUTH")
+	settings.TLSSettings.ClientSubjects = os.Getenv("TLS_CLIENT_SUBJECTS")
+	settings.TLSSettings.RedirectPort = os.Getenv("HTTP_REDIRECT_PORT")
+	settings.HTTP3Enabled, _ = strconv.ParseBool(os.Getenv("HTTP3_ENABLED"))
+	settings.HTTP3Port = os.Getenv("HTTP3_PORT")
+
+	settings.CORSSettings.AllowedOrigins = os.Getenv("CORS_ALLOWED_ORIGINS")
+	settings.CORSSettings.AllowedMethods = os.Getenv("CORS_ALLOWED_METHODS")
+	settings.CORSSettings.AllowedHeaders = envString("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,X-Request-ID,traceparent,Idempotency-Key")
+	settings.CORSSettings.ExposedHeaders = envString("CORS_EXPOSED_HEADERS", "X-Version,X-Request-ID,Retry-After,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Idempotent-Replayed,ETag")
+	settings.CORSSettings.AllowCredentials, _ = strconv.ParseBool(os.Getenv("CORS_ALLOW_CREDENTIALS"))
+	settings.CORSSettings.MaxAge = envInt("CORS_MAX_AGE", 600)
+
+	settings.IPFilterSettings.TrustedProxies = os.Getenv("TRUSTED_PROXIES")
+	settings.IPFilterSettings.ClientIPHeaders = envString("CLIENT_IP_HEADERS", "Forwarded,X-Forwarded-For,X-Real-IP")
+	settings.IPFilterSettings.Allow = os.Getenv("IP_ALLOW")
+	settings.IPFilterSettings.Deny = os.Getenv("IP_DENY")
+	settings.IPFilterSettings.RouteAllow = os.Getenv("ROUTE_IP_ALLOW")
+	settings.IPFilterSettings.RouteDeny = os.Getenv("ROUTE_IP_DENY")
+
+	settings.TracingSettings.Exporter = envString("OTEL_TRACES_EXPORTER", "none")
+	settings.TracingSettings.Endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	settings.TracingSettings.SampleRatio = 1
+	if v, err := strconv.ParseFloat(os.Getenv("OTEL_TRACES_SAMPLER_ARG"), 64); err == nil {
+		settings.TracingSettings.SampleRatio = v
+	}
+
+	settings.FileMakerSettings.Host = os.Getenv("FMS_HOST")
+	settings.FileMakerSettings.Database = os.Getenv("FMS_DATABASE")
+	settings.FileMakerSettings.Username = os.Getenv("FMS_USERNAME")
+	settings.FileMakerSettings.Password = os.Getenv("FMS_PASSWORD")
+	settings.FileMakerSettings.ScriptName = os.Getenv("FMS_SCRIPT_NAME")
+	settings.FileMakerSettings.ScriptSchedule = os.Getenv("FMS_SCRIPT_SCHEDULE")
+	srv.settings = settings
+	return nil
+}
+
+// validateSettings catches configuration mistakes that would otherwise only show up at startup
+func validateSettings(s types.AppSettings) error {
+	var errs []error
+	if s.Port == "" {
+		errs = append(errs, errors.New("PORT must be set"))
+	}
+	if s.Timeout <= 0 {
+		errs = append(errs, errors.New("TIMEOUT must be positive"))
+	}
+	if s.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_TIMEOUT must be positive"))
+	}
+	if _, err := strconv.ParseUint(s.UnixSocketMode, 8, 32); err != nil {
+		errs = append(errs, fmt.Errorf("UNIX_SOCKET_MODE %q is not an octal mode", s.UnixSocketMode))
+	}
+	if s.LogRetentionDays > 0 {
+		if _, err := scheduler.Parse(s.LogPruneSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("LOG_PRUNE_SCHEDULE: %w", err))
+		}
+	}
+	if s.FileMakerSettings.ScriptName != "" {
+		if _, err := scheduler.Parse(s.FileMakerSettings.ScriptSchedule); err != nil {
+			errs = append(errs, fmt.Errorf("FMS_SCRIPT_SCHEDULE: %w", err))
+		}
+	}
+	if _, err := parseRateLimits(s.RateLimits); err != nil {
+		errs = append(errs, fmt.Errorf("RATE_LIMITS: %w", err))
+	}
+	if _, err := ratelimit.ParseLimit(s.RateLimitPreAuth); err != nil {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_PRE_AUTH: %w", err))
+	}
+	if s.RateLimitBackend != "memory" && s.RateLimitBackend != "store" {
+		errs = append(errs, fmt.Errorf("RATE_LIMIT_BACKEND %q must be memory or store", s.RateLimitBackend))
+	}
+	if err := corsPolicy(s).Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("CORS_ALLOWED_ORIGINS: %w", err))
+	}
+	if err := validateIPFilter(s); err != nil {
+		errs = append(errs, err)
+	}
+	switch s.TracingSettings.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_EXPORTER %q must be otlp, stdout or none", s.TracingSettings.Exporter))
+	}
+	if r := s.TracingSettings.SampleRatio; r < 0 || r > 1 {
+		errs = append(errs, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG %v must be between 0 and 1", r))
+	}
+	if s.PanicWebhookURL != "" {
+		if u, err := url.Parse(s.PanicWebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			// The URL is left out, it usually carries a token
+			errs = append(errs, errors.New("PANIC_WEBHOOK_URL must be an http or https URL"))
+		}
+	}
+	if s.IdempotencyTTL <= 0 {
+		errs = append(errs, errors.New("IDEMPOTENCY_TTL must be positive"))
+	}
+	if s.CacheMaxBytes < 0 {
+		errs = append(errs, errors.New("CACHE_MAX_BYTES must not be negative"))
+	}
+	if s.MaintenanceRetryAfter <= 0 {
+		errs = append(errs, errors.New("MAINTENANCE_RETRY_AFTER must be positive"))
+	}
+	if s.UseMySQL && (s.MySqlSettings.Host == "" || s.MySqlSettings.Database == "") {
+		errs = append(errs, errors.New("USE_MYSQL needs MYSQL_HOST and MYSQL_DATABASE"))
+	}
+
+	if s.AdminSystemdSocket != "" && s.AdminPort == "" {
+		errs = append(errs, errors.New("ADMIN_SYSTEMD_SOCKET needs ADMIN_PORT"))
+	}
+	if s.HTTP3Enabled && listener.IsUnix(http3Address(s)) {
+		errs = append(errs, errors.New("HTTP3_ENABLED needs a UDP address, set HTTP3_PORT when PORT is a unix socket"))
+	}
+	if s.TLSSettings.ClientSubjects != "" && s.TLSSettings.ClientCAFile == "" {
+		errs = append(errs, errors.New("TLS_CLIENT_SUBJECTS needs TLS_CLIENT_CA_FILE"))
+	}
+
+	tlsEnabled := s.TLSSettings.CertFile != "" || s.TLSSettings.KeyFile != ""
+	if tlsEnabled {
+		if s.TLSSettings.CertFile == "" || s.TLSSettings.KeyFile == "" {
+			errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+		}
+		for _, f := range []string{s.TLSSettings.CertFile, s.TLSSettings.KeyFile, s.TLSSettings.ClientCAFile} {
+			if f == "" {
+				continue
+			}
+			if _, err := os.Stat(f); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if _, err := tlsutil.ParseVersion(s.TLSSettings.MinVersion); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := tlsutil.ParseCipherPolicy(s.TLSSettings.CipherPolicy); err != nil {
+			errs = append(errs, err)
+		}
+	} else {
+		if s.HTTP3Enabled {
+			errs = append(errs, errors.New("HTTP3_ENABLED needs TLS_CERT_FILE and TLS_KEY_FILE"))
+		}
+		if s.AdminTLS {
+			errs = append(errs, errors.New("ADMIN_TLS needs TLS_CERT_FILE and TLS_KEY_FILE"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// envString reads a string from the environment, falling back to def when unset
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// envInt reads an integer from the environment, falling back to def when unset or invalid
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
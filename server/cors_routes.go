@@ -0,0 +1,71 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cors"
+	"github.com/johansundell/template-service/types"
+)
+
+// corsPolicy builds the default CORS policy from the CORS_* settings
+func corsPolicy(s types.AppSettings) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins:   cors.ParseList(s.CORSSettings.AllowedOrigins),
+		AllowedMethods:   cors.ParseList(strings.ToUpper(s.CORSSettings.AllowedMethods)),
+		AllowedHeaders:   cors.ParseList(s.CORSSettings.AllowedHeaders),
+		ExposedHeaders:   cors.ParseList(s.CORSSettings.ExposedHeaders),
+		AllowCredentials: s.CORSSettings.AllowCredentials,
+		MaxAge:           time.Duration(s.CORSSettings.MaxAge) * time.Second,
+	}
+}
+
+// routeCORS returns the policy a route runs with, its own when it sets one
+func routeCORS(r Route, def cors.Policy) cors.Policy {
+	if r.CORS != nil {
+		return *r.CORS
+	}
+	return def
+}
+
+// CORSMiddleware adds the CORS headers for cross-origin requests
+func CORSMiddleware(p cors.Policy) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if origin := c.GetHeader("Origin"); origin != "" {
+				p.Apply(c.Writer.Header(), origin)
+			}
+			return inner(c)
+		}
+	}
+}
+
+// preflightHandler answers OPTIONS for a path, using the policy of the route
+// registered for the method the browser asks about
+func preflightHandler(routes []Route, def cors.Policy) HandlerFuncWithError {
+	methods := make([]string, 0, len(routes)+1)
+	for _, r := range routes {
+		methods = append(methods, r.Method)
+	}
+	methods = append(methods, http.MethodOptions)
+
+	return func(c *gin.Context) error {
+		method := c.GetHeader("Access-Control-Request-Method")
+		if method == "" {
+			// A plain OPTIONS request, not a preflight
+			c.Header("Allow", strings.Join(methods, ", "))
+			c.Status(http.StatusNoContent)
+			return nil
+		}
+		for _, r := range routes {
+			if strings.EqualFold(r.Method, method) {
+				routeCORS(r, def).Preflight(c.Writer.Header(), c.GetHeader("Origin"), method, c.GetHeader("Access-Control-Request-Headers"), []string{r.Method})
+				break
+			}
+		}
+		c.Status(http.StatusNoContent)
+		return nil
+	}
+}
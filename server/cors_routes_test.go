@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestCORS(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	s := store.NewStorage(db)
+	h := handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+
+	var corsSettings types.AppSettings
+	corsSettings.CORSSettings.AllowedOrigins = "https://*.example.com"
+	corsSettings.CORSSettings.AllowedHeaders = "Authorization,Content-Type"
+	corsSettings.CORSSettings.MaxAge = 600
+	router := newTestRouter(t, h, s, corsSettings)
+
+	t.Run("Preflight", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "/pong", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("Expected status 204, got %d", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Expected the origin to be allowed, got %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Methods"); got != "POST" {
+			t.Errorf("Expected POST to be allowed, got %q", got)
+		}
+		if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+			t.Errorf("Expected max age 600, got %q", got)
+		}
+	})
+
+	t.Run("Preflight from a foreign origin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("OPTIONS", "/pong", nil)
+		req.Header.Set("Origin", "https://evil.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "" {
+			t.Errorf("Expected no CORS headers, got %q", got)
+		}
+	})
+
+	t.Run("Actual request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/ping/test", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+			t.Errorf("Expected the origin to be allowed, got %q", got)
+		}
+	})
+}
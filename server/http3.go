@@ -0,0 +1,47 @@
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/johansundell/template-service/listener"
+	"github.com/johansundell/template-service/types"
+	"github.com/quic-go/quic-go/http3"
+)
+
+// newHTTP3Server creates an HTTP/3 server that shares the TLS config of the main server
+func newHTTP3Server(handler http.Handler, tlsConf *tls.Config) *http3.Server {
+	return &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(tlsConf),
+	}
+}
+
+// http3Address returns the UDP address for HTTP/3, which defaults to the TCP port
+func http3Address(s types.AppSettings) string {
+	if s.HTTP3Port != "" {
+		return s.HTTP3Port
+	}
+	return s.Port
+}
+
+// listenHTTP3 binds the UDP socket up front so a taken port fails startup
+func listenHTTP3(addr string) (net.PacketConn, error) {
+	if listener.IsUnix(addr) {
+		return nil, errors.New("HTTP/3 needs a UDP address, set HTTP3_PORT when PORT is a unix socket")
+	}
+	return net.ListenPacket("udp", addr)
+}
+
+// altSvcHandler advertises the HTTP/3 listener to clients connecting over TCP
+func altSvcHandler(h3 *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor < 3 {
+			// Fails only until the listener is up, the header is left out then
+			h3.SetQUICHeaders(w.Header())
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/idempotency"
+	"github.com/johansundell/template-service/ratelimit"
+)
+
+// idempotencyLock is how long a key stays claimed by a request on a route without a timeout
+const idempotencyLock = 5 * time.Minute
+
+// idempotencyPoll is how often a duplicate request checks whether the first one has finished
+const idempotencyPoll = 100 * time.Millisecond
+
+// unstoredHeaders describe the encoding of one response, CompressionMiddleware
+// sets them again for the client of a replay
+var unstoredHeaders = []string{"Content-Encoding", "Content-Length", "Vary"}
+
+// IdempotencyMiddleware replays the first response to requests repeating an
+// Idempotency-Key. A key reused with a different method, URL or body is
+// answered with 422, and a duplicate arriving while the first request still
+// runs waits for its response until the request context ends, then gets a
+// 409. Only successful responses are stored, errors release the key so that
+// the client can retry. Requests without the header pass through.
+func IdempotencyMiddleware(s idempotency.Store, route string, ttl, lock time.Duration) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			key := c.GetHeader(idempotency.Header)
+			if key == "" {
+				return inner(c)
+			}
+			if !idempotency.ValidKey(key) {
+				return httperror.ReturnWithHTTPStatus(idempotency.ErrInvalidKey, http.StatusBadRequest)
+			}
+
+			var body []byte
+			if c.Request.Body != nil {
+				var err error
+				body, err = io.ReadAll(c.Request.Body)
+				c.Request.Body.Close()
+				if err != nil {
+					return httperror.ReturnWithHTTPStatus(err, http.StatusBadRequest)
+				}
+				c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			// Keys are scoped to the client and route, so clients can't see each other's responses
+			key = idempotency.Hash([]byte(rateLimitKey(c, route, ratelimit.ByToken)), []byte(key))
+			hash := idempotency.Hash([]byte(c.Request.Method), []byte(c.Request.URL.RequestURI()), body)
+
+			ctx := c.Request.Context()
+			rec, claimed, err := idempotency.Wait(ctx, s, key, hash, lock, idempotencyPoll)
+			switch {
+			case errors.Is(err, idempotency.ErrMismatch):
+				return httperror.ReturnWithHTTPStatus(err, http.StatusUnprocessableEntity)
+			case errors.Is(err, idempotency.ErrInProgress):
+				return httperror.ReturnWithHTTPStatus(err, http.StatusConflict)
+			case err != nil:
+				// Don't take the service down with the idempotency backend
+				slog.ErrorContext(ctx, "Idempotency check failed", "error", err)
+				return inner(c)
+			case !claimed:
+				replay(c, rec.Response)
+				return nil
+			}
+
+			completed := false
+			defer func() {
+				if !completed {
+					// The request may have timed out, the release must not depend on its context
+					if err := s.Release(context.WithoutCancel(ctx), key); err != nil {
+						slog.ErrorContext(ctx, "Failed to release idempotency key", "error", err)
+					}
+				}
+			}()
+
+			before := c.Writer.Header().Clone()
+			w := &bodyLogWriter{body: &bytes.Buffer{}, ResponseWriter: c.Writer}
+			c.Writer = w
+
+			err = inner(c)
+			if err != nil || c.Writer.Status() >= http.StatusInternalServerError {
+				return err
+			}
+
+			res := idempotency.Response{Status: c.Writer.Status(), Header: http.Header{}, Body: w.body.Bytes()}
+			// Only the headers set by the handler are stored, the outer middleware sets its own on replay
+			for k, v := range c.Writer.Header() {
+				if !slices.Equal(before[k], v) && !slices.Contains(unstoredHeaders, k) {
+					res.Header[k] = v
+				}
+			}
+			if err := s.Complete(context.WithoutCancel(ctx), key, res, ttl); err != nil {
+				slog.ErrorContext(ctx, "Failed to store idempotent response", "error", err)
+				return nil
+			}
+			completed = true
+			return nil
+		}
+	}
+}
+
+// replay writes a stored response
+func replay(c *gin.Context, res *idempotency.Response) {
+	for k, v := range res.Header {
+		c.Writer.Header()[k] = v
+	}
+	c.Header(idempotency.ReplayedHeader, "true")
+	c.Status(res.Status)
+	c.Writer.Write(res.Body)
+}
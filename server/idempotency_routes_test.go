@@ -0,0 +1,168 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/compression"
+	"github.com/johansundell/template-service/idempotency"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	create := func(c *gin.Context) error {
+		n := calls.Add(1)
+		if c.Query("wait") != "" {
+			<-release
+		}
+		c.Header("Location", fmt.Sprintf("/things/%d", n))
+		c.String(http.StatusCreated, "created %d", n)
+		return nil
+	}
+	router := gin.New()
+	router.POST("/things", WrapHandler(IdempotencyMiddleware(idempotency.NewMemoryStore(), "Create", time.Hour, time.Minute)(create)))
+
+	call := func(url, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", url, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotency.Header, key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := call("/things", "k1", `{"a":1}`)
+	if first.Code != http.StatusCreated || first.Body.String() != "created 1" {
+		t.Fatalf("Expected the first request to run, got %d %q", first.Code, first.Body.String())
+	}
+
+	retry := call("/things", "k1", `{"a":1}`)
+	if retry.Code != http.StatusCreated || retry.Body.String() != "created 1" {
+		t.Errorf("Expected the stored response, got %d %q", retry.Code, retry.Body.String())
+	}
+	if got := retry.Header().Get("Location"); got != "/things/1" {
+		t.Errorf("Expected the stored Location header, got %q", got)
+	}
+	if got := retry.Header().Get(idempotency.ReplayedHeader); got != "true" {
+		t.Errorf("Expected %s: true, got %q", idempotency.ReplayedHeader, got)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected the handler to run once, ran %d times", calls.Load())
+	}
+
+	if w := call("/things", "k1", `{"a":2}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a different body to get 422, got %d", w.Code)
+	}
+	if w := call("/things", "", `{"a":1}`); w.Code != http.StatusCreated || calls.Load() != 2 {
+		t.Errorf("Expected a request without a key to run, got %d", w.Code)
+	}
+	if w := call("/things", strings.Repeat("k", 256), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a long key to get 400, got %d", w.Code)
+	}
+
+	// Concurrent duplicates wait for the first response instead of running again
+	var wg sync.WaitGroup
+	results := make([]*httptest.ResponseRecorder, 3)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = call("/things?wait=1", "k2", `{}`)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for _, w := range results {
+		if w.Code != http.StatusCreated || w.Body.String() != "created 3" {
+			t.Errorf("Expected every duplicate to get the first response, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected the concurrent duplicates to run the handler once, ran %d times", calls.Load()-2)
+	}
+}
+
+func TestIdempotencyMiddlewareReleasesFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	fail := true
+	handler := func(c *gin.Context) error {
+		if fail {
+			return fmt.Errorf("backend down")
+		}
+		c.String(http.StatusOK, "ok")
+		return nil
+	}
+	router := gin.New()
+	router.POST("/things", WrapHandler(IdempotencyMiddleware(idempotency.NewMemoryStore(), "Create", time.Hour, time.Minute)(handler)))
+
+	call := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/things", strings.NewReader("{}"))
+		req.Header.Set(idempotency.Header, "k")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected the failure to be answered, got %d", w.Code)
+	}
+	fail = false
+	if w := call(); w.Code != http.StatusOK || w.Header().Get(idempotency.ReplayedHeader) != "" {
+		t.Errorf("Expected a retry after a failure to run the handler, got %d", w.Code)
+	}
+}
+
+func TestIdempotencyMiddlewareCompression(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := `[` + strings.Repeat(`{"status":201},`, 100) + `{"status":201}]`
+	router := gin.New()
+	router.Use(CompressionMiddleware(compression.Config{MinSize: 1024, ContentTypes: compression.DefaultContentTypes}))
+	router.POST("/things", WrapHandler(IdempotencyMiddleware(idempotency.NewMemoryStore(), "Create", time.Hour, time.Minute)(func(c *gin.Context) error {
+		c.Data(http.StatusCreated, "application/json", []byte(body))
+		return nil
+	})))
+
+	call := func(acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/things", strings.NewReader(`{}`))
+		req.Header.Set(idempotency.Header, "k1")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := call("br")
+		if w.Header().Get("Content-Encoding") != "br" || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("Expected request %d to be compressed once, got %q and Vary %q", i+1, w.Header().Values("Content-Encoding"), w.Header().Values("Vary"))
+		}
+		got, err := io.ReadAll(brotli.NewReader(w.Body))
+		if err != nil || string(got) != body {
+			t.Errorf("Expected request %d to decode to the body, got %v", i+1, err)
+		}
+	}
+
+	// The stored response is not compressed, clients without brotli get it as it is
+	w := call("")
+	if w.Code != http.StatusCreated || w.Header().Get("Content-Encoding") != "" || w.Body.String() != body {
+		t.Errorf("Expected the plain body, got %d %q", w.Code, w.Header().Get("Content-Encoding"))
+	}
+}
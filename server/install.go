@@ -0,0 +1,159 @@
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kardianos/service"
+)
+
+// stringList is a flag that can be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// systemdRestartPolicies are the values systemd accepts for Restart=
+var systemdRestartPolicies = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+// windowsRestartPolicies are the values kardianos accepts for OnFailure
+var windowsRestartPolicies = []string{"restart", "reboot", "noaction"}
+
+// installOptions are the flags used by -service install
+type installOptions struct {
+	user         string
+	workDir      string
+	envFile      string
+	args         string
+	dependencies stringList
+	restart      string
+}
+
+func (o *installOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.user, "user", "", "Run the installed service as this user.")
+	fs.StringVar(&o.workDir, "workdir", "", "Working directory of the installed service.")
+	fs.StringVar(&o.args, "args", "", "Extra arguments passed to the installed service.")
+	fs.Var(&o.dependencies, "depends", "Service dependency, e.g. After=network-online.target. Can be repeated.")
+	fs.StringVar(&o.restart, "restart", "", "Restart policy of the installed service, e.g. on-failure.")
+}
+
+// serviceConfig builds the kardianos config. The env file is passed on to
+// the service as an argument, so edits to it only need a restart.
+func (srv *Server) serviceConfig(o *installOptions) *service.Config {
+	cfg := &service.Config{
+		Name:             srv.name,
+		DisplayName:      srv.name,
+		Description:      srv.name,
+		UserName:         o.user,
+		WorkingDirectory: o.workDir,
+		Dependencies:     append([]string{}, o.dependencies...),
+		Option:           service.KeyValue{},
+		Arguments:        []string{"serve"},
+	}
+	if o.envFile != "" {
+		cfg.Arguments = append(cfg.Arguments, "-envfile", o.envFile)
+	}
+	cfg.Arguments = append(cfg.Arguments, strings.Fields(o.args)...)
+
+	if o.restart != "" {
+		if runtime.GOOS == "windows" {
+			cfg.Option["OnFailure"] = o.restart
+		} else {
+			cfg.Option["Restart"] = o.restart
+		}
+	}
+	if srv.usesSocketActivation() {
+		cfg.Dependencies = append(cfg.Dependencies, srv.socketActivationDependencies()...)
+	}
+	return cfg
+}
+
+// validate checks the options before anything is written, and makes paths
+// absolute since the service manager doesn't start us from this directory.
+func (o *installOptions) validate() error {
+	var errs []error
+
+	if o.user != "" {
+		if _, err := user.Lookup(o.user); err != nil {
+			errs = append(errs, fmt.Errorf("user %q: %w", o.user, err))
+		}
+	}
+
+	if o.workDir != "" {
+		abs, err := filepath.Abs(o.workDir)
+		if err == nil {
+			o.workDir = abs
+		}
+		info, err := os.Stat(o.workDir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("working directory: %w", err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("working directory %s is not a directory", o.workDir))
+		}
+	}
+
+	if o.envFile != "" {
+		abs, err := filepath.Abs(o.envFile)
+		if err == nil {
+			o.envFile = abs
+		}
+		if _, err := os.Stat(o.envFile); err != nil {
+			errs = append(errs, fmt.Errorf("environment file: %w", err))
+		}
+	}
+
+	if o.restart != "" {
+		allowed := systemdRestartPolicies
+		if runtime.GOOS == "windows" {
+			allowed = windowsRestartPolicies
+		}
+		if !slices.Contains(allowed, o.restart) {
+			errs = append(errs, fmt.Errorf("restart policy %q is not one of %s", o.restart, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if runtime.GOOS == "linux" {
+		for _, d := range o.dependencies {
+			if key, _, ok := strings.Cut(d, "="); !ok || key == "" {
+				errs = append(errs, fmt.Errorf("dependency %q should look like After=network-online.target", d))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// printStatus reports whether the service is installed and running
+func (srv *Server) printStatus(s service.Service) error {
+	status, err := s.Status()
+	if errors.Is(err, service.ErrNotInstalled) {
+		fmt.Printf("%s: not installed\n", srv.name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := "unknown"
+	switch status {
+	case service.StatusRunning:
+		state = "running"
+	case service.StatusStopped:
+		state = "stopped"
+	}
+	fmt.Printf("%s: installed, %s\n", srv.name, state)
+	return nil
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/ipfilter"
+	"github.com/johansundell/template-service/types"
+)
+
+// errAddressNotAllowed is returned to clients outside a route's IP lists
+var errAddressNotAllowed = errors.New("client address is not allowed")
+
+// clientIPResolver builds the client address resolver from TRUSTED_PROXIES and CLIENT_IP_HEADERS
+func clientIPResolver(s types.AppSettings) ipfilter.Resolver {
+	// setup rejects broken ranges before the routers are built
+	trusted, unix, _ := ipfilter.ParseTrusted(s.IPFilterSettings.TrustedProxies)
+	var headers []string
+	for _, h := range strings.Split(s.IPFilterSettings.ClientIPHeaders, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, h)
+		}
+	}
+	return ipfilter.Resolver{Trusted: trusted, TrustUnix: unix, Headers: headers}
+}
+
+// globalIPList builds the IP_ALLOW and IP_DENY lists
+func globalIPList(s types.AppSettings) ipfilter.List {
+	allow, _ := ipfilter.ParsePrefixes(s.IPFilterSettings.Allow)
+	deny, _ := ipfilter.ParsePrefixes(s.IPFilterSettings.Deny)
+	return ipfilter.List{Allow: allow, Deny: deny}
+}
+
+// parseRouteIPLists reads ROUTE_IP_ALLOW or ROUTE_IP_DENY, a comma separated
+// list of Route=ranges pairs with the ranges separated by pipes, like
+// "GetLogs=10.0.0.0/8|192.168.1.0/24,Metrics=10.1.0.0/16"
+func parseRouteIPLists(spec string) (map[string][]netip.Prefix, error) {
+	lists := map[string][]netip.Prefix{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("IP list %q must look like Route=10.0.0.0/8|192.168.0.0/16", part)
+		}
+		prefixes, err := ipfilter.ParsePrefixes(value)
+		if err != nil {
+			return nil, err
+		}
+		lists[strings.TrimSpace(name)] = prefixes
+	}
+	return lists, nil
+}
+
+// routeIPList returns the lists a route runs with, ROUTE_IP_ALLOW and ROUTE_IP_DENY replace the route's own
+func routeIPList(r Route, allow, deny map[string][]netip.Prefix) ipfilter.List {
+	l := r.IPs
+	if prefixes, ok := allow[r.Name]; ok {
+		l.Allow = prefixes
+	}
+	if prefixes, ok := deny[r.Name]; ok {
+		l.Deny = prefixes
+	}
+	return l
+}
+
+// validateIPFilter checks the ranges of the IP filter settings
+func validateIPFilter(s types.AppSettings) error {
+	var errs []error
+	if _, _, err := ipfilter.ParseTrusted(s.IPFilterSettings.TrustedProxies); err != nil {
+		errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %w", err))
+	}
+	for _, l := range []struct{ name, spec string }{
+		{"IP_ALLOW", s.IPFilterSettings.Allow},
+		{"IP_DENY", s.IPFilterSettings.Deny},
+	} {
+		if _, err := ipfilter.ParsePrefixes(l.spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.name, err))
+		}
+	}
+	for _, l := range []struct{ name, spec string }{
+		{"ROUTE_IP_ALLOW", s.IPFilterSettings.RouteAllow},
+		{"ROUTE_IP_DENY", s.IPFilterSettings.RouteDeny},
+	} {
+		if _, err := parseRouteIPLists(l.spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ClientIPMiddleware replaces the request's remote address with the client
+// address resolved behind trusted proxies, so that c.ClientIP, the rate
+// limits and the request log all see the same client. Clients outside the
+// global lists are answered with 403.
+func ClientIPMiddleware(r ipfilter.Resolver, global ipfilter.List) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ip := r.ClientIP(c.Request); ip.IsValid() {
+			port := uint16(0)
+			if ap, err := netip.ParseAddrPort(c.Request.RemoteAddr); err == nil {
+				port = ap.Port()
+			}
+			c.Request.RemoteAddr = netip.AddrPortFrom(ip, port).String()
+		}
+		if global.Enabled() && !global.Allows(remoteIP(c)) {
+			c.String(http.StatusForbidden, http.StatusText(http.StatusForbidden))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// IPFilterMiddleware answers 403 to clients outside the route's lists
+func IPFilterMiddleware(l ipfilter.List) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if !l.Allows(remoteIP(c)) {
+				return httperror.ReturnWithHTTPStatus(errAddressNotAllowed, http.StatusForbidden)
+			}
+			return inner(c)
+		}
+	}
+}
+
+// remoteIP returns the client address ClientIPMiddleware resolved
+func remoteIP(c *gin.Context) netip.Addr {
+	addr, _ := netip.ParseAddr(c.ClientIP())
+	return addr.Unmap()
+}
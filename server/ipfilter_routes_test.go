@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/ipfilter"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestClientIPMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var s types.AppSettings
+	s.IPFilterSettings.TrustedProxies = "10.0.0.0/8"
+	s.IPFilterSettings.ClientIPHeaders = "X-Forwarded-For"
+	s.IPFilterSettings.Deny = "198.51.100.0/24"
+
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.Use(ClientIPMiddleware(clientIPResolver(s), globalIPList(s)))
+	router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+	call := func(remote, forwarded string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req.RemoteAddr = remote
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-For", forwarded)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call("10.0.0.1:4000", "203.0.113.7"); w.Body.String() != "203.0.113.7" {
+		t.Errorf("Expected the forwarded address behind a trusted proxy, got %q", w.Body.String())
+	}
+	if w := call("203.0.113.8:4000", "203.0.113.7"); w.Body.String() != "203.0.113.8" {
+		t.Errorf("Expected the peer address from an untrusted peer, got %q", w.Body.String())
+	}
+	if w := call("10.0.0.1:4000", "198.51.100.3"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected a denied client behind the proxy to get 403, got %d", w.Code)
+	}
+
+	t.Run("unix socket", func(t *testing.T) {
+		s.IPFilterSettings.TrustedProxies = "unix"
+		if err := validateIPFilter(s); err != nil {
+			t.Fatalf("Expected the unix token to be valid, got %v", err)
+		}
+		router := gin.New()
+		router.Use(ClientIPMiddleware(clientIPResolver(s), ipfilter.List{}))
+		router.GET("/ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/ip", nil)
+		req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "/run/app.sock", Net: "unix"}))
+		req.RemoteAddr = "@"
+		req.Header.Set("X-Forwarded-For", "203.0.113.7")
+		router.ServeHTTP(w, req)
+		if w.Body.String() != "203.0.113.7" {
+			t.Errorf("Expected the forwarded address from the socket peer, got %q", w.Body.String())
+		}
+	})
+}
+
+func TestIPFilterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lists, err := parseRouteIPLists("GetLogs=10.0.0.0/8|192.168.1.0/24, Metrics=10.1.0.0/16")
+	if err != nil {
+		t.Fatalf("parseRouteIPLists failed: %v", err)
+	}
+	if len(lists["GetLogs"]) != 2 || len(lists["Metrics"]) != 1 {
+		t.Fatalf("Unexpected lists %v", lists)
+	}
+
+	l := routeIPList(Route{Name: "GetLogs"}, lists, nil)
+	ok := func(c *gin.Context) error {
+		c.String(http.StatusOK, "ok")
+		return nil
+	}
+	router := gin.New()
+	router.GET("/logs", WrapHandler(IPFilterMiddleware(l)(ok)))
+
+	for remote, want := range map[string]int{
+		"192.168.1.20:4000": http.StatusOK,
+		"10.2.3.4:4000":     http.StatusOK,
+		"203.0.113.1:4000":  http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/logs", nil)
+		req.RemoteAddr = remote
+		router.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("Expected %d for %s, got %d", want, remote, w.Code)
+		}
+	}
+
+	if routeIPList(Route{Name: "Ping"}, lists, nil).Enabled() {
+		t.Errorf("Expected routes without lists to be open")
+	}
+	own := Route{Name: "Metrics", IPs: ipfilter.List{Deny: lists["GetLogs"]}}
+	if got := routeIPList(own, lists, nil); len(got.Allow) != 1 || len(got.Deny) != 2 {
+		t.Errorf("Expected ROUTE_IP_ALLOW to replace only the allow list, got %+v", got)
+	}
+}
+
+func TestValidateIPFilter(t *testing.T) {
+	var s types.AppSettings
+	s.IPFilterSettings.TrustedProxies = "10.0.0.0/8"
+	s.IPFilterSettings.RouteAllow = "GetLogs=10.0.0.0/8"
+	if err := validateIPFilter(s); err != nil {
+		t.Errorf("Expected valid settings, got %v", err)
+	}
+
+	s.IPFilterSettings.Allow = "office"
+	s.IPFilterSettings.RouteDeny = "GetLogs"
+	if err := validateIPFilter(s); err == nil {
+		t.Errorf("Expected broken ranges to be rejected")
+	}
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/johansundell/template-service/scheduler"
+	"github.com/johansundell/template-service/store"
+)
+
+// newScheduler registers the configured background jobs
+func (srv *Server) newScheduler(storage *store.Storage) (*scheduler.Scheduler, error) {
+	settings := srv.settings
+	timeout := time.Duration(settings.JobTimeout) * time.Second
+	jitter := time.Duration(settings.JobJitter) * time.Second
+
+	s := scheduler.New()
+	if settings.LogRetentionDays > 0 {
+		err := s.Add(scheduler.Job{
+			Name:    "prune-logs",
+			Spec:    settings.LogPruneSchedule,
+			Timeout: timeout,
+			Jitter:  jitter,
+			Func: func(ctx context.Context) error {
+				before := time.Now().AddDate(0, 0, -settings.LogRetentionDays)
+				n, err := storage.PruneLogsContext(ctx, before)
+				if err != nil {
+					return err
+				}
+				srv.logger.Infof("Pruned %d request logs older than %s", n, before.Format(dateLayout))
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if name := settings.FileMakerSettings.ScriptName; name != "" {
+		client := srv.newFileMakerClient()
+		err := s.Add(scheduler.Job{
+			Name:    "filemaker-script",
+			Spec:    settings.FileMakerSettings.ScriptSchedule,
+			Timeout: timeout,
+			Jitter:  jitter,
+			Func: func(ctx context.Context) error {
+				res, err := client.RunScript(ctx, name, nil)
+				if err != nil {
+					return err
+				}
+				if res.Code != 0 {
+					return fmt.Errorf("script %s failed with code %d", name, res.Code)
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if limits, ok := srv.rateLimits.(*store.RateLimitStore); ok {
+		err := s.Add(scheduler.Job{
+			Name:    "prune-rate-limits",
+			Spec:    "@hourly",
+			Timeout: timeout,
+			Jitter:  jitter,
+			Func: func(ctx context.Context) error {
+				// Buckets idle for a day are full again for any sensible limit
+				_, err := limits.Prune(time.Now().Add(-24 * time.Hour))
+				return err
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if keys, ok := srv.idempotencyStore.(*store.IdempotencyStore); ok {
+		err := s.Add(scheduler.Job{
+			Name:    "prune-idempotency-keys",
+			Spec:    "@hourly",
+			Timeout: timeout,
+			Jitter:  jitter,
+			Func: func(ctx context.Context) error {
+				_, err := keys.Prune(time.Now())
+				return err
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
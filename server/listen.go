@@ -0,0 +1,50 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/johansundell/template-service/listener"
+	"github.com/johansundell/template-service/types"
+)
+
+// FileDescriptorName= of the sockets in a systemd socket unit
+const (
+	publicSocketName = "http"
+	adminSocketName  = "admin"
+)
+
+// listenOptions returns the permissions for Unix domain sockets
+func listenOptions(s types.AppSettings) (listener.Options, error) {
+	mode, err := strconv.ParseUint(s.UnixSocketMode, 8, 32)
+	if err != nil {
+		return listener.Options{}, fmt.Errorf("invalid UNIX_SOCKET_MODE %q: %w", s.UnixSocketMode, err)
+	}
+	return listener.Options{Mode: os.FileMode(mode), Group: s.UnixSocketGroup}, nil
+}
+
+// listen returns the socket handed over by systemd if there is one, and
+// otherwise opens addr, which may be a TCP address or "unix:/path".
+func (srv *Server) listen(sockets []listener.Socket, name, addr string) (net.Listener, error) {
+	if ln, ok := listener.Find(sockets, name); ok {
+		srv.logger.Infof("Using socket %q from systemd for %s", name, addr)
+		return ln, nil
+	}
+	// A single socket without a name we know is the public one
+	if name == publicSocketName && len(sockets) == 1 {
+		srv.logger.Info("Using socket from systemd")
+		return sockets[0].Listener, nil
+	}
+
+	opts, err := listenOptions(srv.settings)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := listener.Listen(addr, opts)
+	if err != nil {
+		return nil, fmt.Errorf("listen on %s: %w", addr, err)
+	}
+	return ln, nil
+}
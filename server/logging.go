@@ -0,0 +1,18 @@
+package server
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/johansundell/template-service/requestid"
+)
+
+// newLogger creates the structured logger, records logged with a request
+// context carry its request ID
+func newLogger(debug bool) *slog.Logger {
+	level := slog.LevelInfo
+	if debug {
+		level = slog.LevelDebug
+	}
+	return slog.New(requestid.NewLogHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})))
+}
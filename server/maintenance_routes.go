@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/requestid"
+)
+
+// maintenanceWatchInterval is how often the MAINTENANCE_FILE flag file is checked
+const maintenanceWatchInterval = 2 * time.Second
+
+// maintenanceAllowed reports whether a route keeps working in maintenance mode.
+// Admin routes always do, public ones when they set DuringMaintenance or are
+// listed in MAINTENANCE_ALLOW.
+func maintenanceAllowed(r Route, allow []string) bool {
+	return r.Listener == AdminListener || r.DuringMaintenance || slices.Contains(allow, r.Name)
+}
+
+// splitList reads a comma separated list, like route names or certificate subjects
+func splitList(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// MaintenanceMiddleware answers 503 with Retry-After while maintenance mode
+// is on, as the HTML page when the client prefers HTML and as JSON otherwise
+func MaintenanceMiddleware(m *maintenance.Mode, page func(*gin.Context, maintenance.State) error) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			state := m.State()
+			if !state.Enabled {
+				return inner(c)
+			}
+
+			c.Header("Retry-After", strconv.Itoa(state.RetryAfter))
+			c.Header("Cache-Control", "no-store")
+			if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+				return page(c, state)
+			}
+			detail := state.Reason
+			if detail == "" {
+				detail = "The service is down for maintenance"
+			}
+			c.JSON(http.StatusServiceUnavailable, errorResponse{
+				Status:    http.StatusServiceUnavailable,
+				Error:     http.StatusText(http.StatusServiceUnavailable),
+				Detail:    detail,
+				RequestID: requestid.FromContext(c.Request.Context()),
+			})
+			return nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/maintenance"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := maintenance.New("", 300, nil)
+	page := func(c *gin.Context, s maintenance.State) error {
+		c.String(http.StatusServiceUnavailable, "page: "+s.Reason)
+		return nil
+	}
+	ok := func(c *gin.Context) error {
+		c.String(http.StatusOK, "ok")
+		return nil
+	}
+	router := gin.New()
+	router.GET("/business", WrapHandler(MaintenanceMiddleware(m, page)(ok)))
+
+	call := func(accept string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/business", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(""); w.Code != http.StatusOK {
+		t.Fatalf("Expected the route to work outside maintenance, got %d", w.Code)
+	}
+
+	m.Set(context.Background(), true, "database upgrade", 120, "test", "")
+
+	w := call("application/json")
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "120" {
+		t.Errorf("Expected 503 with Retry-After 120, got %d %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	var body errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Detail != "database upgrade" {
+		t.Errorf("Expected a JSON body with the reason, got %s", w.Body.String())
+	}
+	if w := call("*/*"); w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("Expected JSON for any content type, got %q", w.Header().Get("Content-Type"))
+	}
+	if w := call("text/html,application/xhtml+xml,*/*;q=0.8"); w.Body.String() != "page: database upgrade" {
+		t.Errorf("Expected the HTML page for browsers, got %q", w.Body.String())
+	}
+}
+
+func TestMaintenanceAllowed(t *testing.T) {
+	allow := splitList("Ping, Docs")
+	tests := []struct {
+		route Route
+		want  bool
+	}{
+		{Route{Name: "Pong"}, false},
+		{Route{Name: "Ping"}, true},
+		{Route{Name: "HealthCheck", DuringMaintenance: true}, true},
+		{Route{Name: "GetLogs", Listener: AdminListener}, true},
+	}
+	for _, tt := range tests {
+		if got := maintenanceAllowed(tt.route, allow); got != tt.want {
+			t.Errorf("maintenanceAllowed(%s) = %v, want %v", tt.route.Name, got, tt.want)
+		}
+	}
+}
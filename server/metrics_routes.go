@@ -0,0 +1,29 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/metrics"
+)
+
+// MetricsMiddleware records the count, latency and in-flight requests of a route
+func MetricsMiddleware(m *metrics.Metrics, route string) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			done := m.StartRequest(route, c.Request.Method)
+			err := inner(c)
+			if err != nil {
+				done(httperror.HTTPStatus(err))
+			} else {
+				done(c.Writer.Status())
+			}
+			return err
+		}
+	}
+}
+
+// metricsHandler serves the metrics in the Prometheus text format
+func (srv *Server) metricsHandler(c *gin.Context) error {
+	srv.metrics.Handler().ServeHTTP(c.Writer, c.Request)
+	return nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestMetricsRoute(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	s := store.NewStorage(db)
+	h := handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+
+	var metricsSettings types.AppSettings
+	metricsSettings.AuthToken = "secret-token"
+	router := newTestRouter(t, h, s, metricsSettings)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	get("/ping/test", "")
+
+	if w := get("/metrics", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected /metrics to need the auth token, got %d", w.Code)
+	}
+	w := get("/metrics", "secret-token")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `template_service_http_requests_total{method="GET",route="Ping",status="2xx"}`) {
+		t.Errorf("Expected the Ping request to be counted")
+	}
+}
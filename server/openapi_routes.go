@@ -0,0 +1,208 @@
+package server
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/idempotency"
+	"github.com/johansundell/template-service/openapi"
+	"github.com/johansundell/template-service/validation"
+)
+
+// pathParam matches the :name and *name segments of a gin pattern
+var pathParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// openAPIPath turns a gin pattern into an OpenAPI path and its parameters,
+// typed as declared in declared and as strings otherwise
+func openAPIPath(pattern string, declared []validation.Param) (string, []openapi.Parameter) {
+	var params []openapi.Parameter
+	for _, m := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		p := validation.Param{Name: m[1], In: validation.InPath}
+		for _, d := range declared {
+			if d.In == validation.InPath && d.Name == p.Name {
+				p = d
+			}
+		}
+		params = append(params, openAPIParam(p))
+	}
+	for _, d := range declared {
+		if d.In == validation.InQuery {
+			params = append(params, openAPIParam(d))
+		}
+	}
+	return pathParam.ReplaceAllString(pattern, "{$1}"), params
+}
+
+// openAPIParam describes a declared parameter
+func openAPIParam(p validation.Param) openapi.Parameter {
+	s := &openapi.Schema{Type: string(p.Type)}
+	switch p.Type {
+	case "":
+		s.Type = "string"
+	case validation.Date:
+		s.Type, s.Format = "string", "date"
+	}
+	required := openapi.ApplyRules(s, p.Rules)
+	return openapi.Parameter{
+		Name:        p.Name,
+		In:          p.In,
+		Description: p.Description,
+		Required:    required || p.In == validation.InPath,
+		Schema:      s,
+	}
+}
+
+// openAPIDocument describes routes as they run with the server's settings,
+// including the errors added by auth, rate limits and timeouts
+func (srv *Server) openAPIDocument(routes Routes) *openapi.Document {
+	settings := srv.settings
+	gen := openapi.NewGenerator()
+	overrides, _ := parseRateLimits(settings.RateLimits)
+	routeAllow, _ := parseRouteIPLists(settings.IPFilterSettings.RouteAllow)
+	routeDeny, _ := parseRouteIPLists(settings.IPFilterSettings.RouteDeny)
+	globalIPs := globalIPList(settings)
+	defaultTimeout := time.Duration(settings.Timeout) * time.Second
+
+	doc := &openapi.Document{
+		OpenAPI: openapi.Version,
+		Info:    openapi.Info{Title: srv.name, Version: srv.version},
+		Tags: []openapi.Tag{
+			{Name: "public", Description: "Served on PORT."},
+			{Name: "admin", Description: "Served on ADMIN_PORT when it is set, on PORT otherwise."},
+		},
+		Paths: map[string]openapi.PathItem{},
+		Components: openapi.Components{
+			SecuritySchemes: map[string]*openapi.SecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", Description: "The AUTH_TOKEN, the Bearer prefix is optional."},
+			},
+		},
+	}
+	security := []openapi.SecurityRequirement{{"bearerAuth": {}}}
+	if len(clientSubjects(settings)) > 0 {
+		doc.Components.SecuritySchemes["mutualTLS"] = &openapi.SecurityScheme{Type: "mutualTLS", Description: "A client certificate signed by TLS_CLIENT_CA_FILE, with a subject listed in TLS_CLIENT_SUBJECTS."}
+		security = append(security, openapi.SecurityRequirement{"mutualTLS": {}})
+	}
+	errorSchema := gen.Schema(errorResponse{})
+
+	for _, r := range routes {
+		path, params := openAPIPath(r.Pattern, r.Params)
+		op := &openapi.Operation{
+			OperationID: r.Name,
+			Summary:     r.Summary,
+			Tags:        []string{"public"},
+			Parameters:  params,
+			Responses:   map[string]*openapi.Response{},
+		}
+		if r.Listener == AdminListener {
+			op.Tags = []string{"admin"}
+		}
+		if r.Request != nil {
+			op.RequestBody = &openapi.RequestBody{Required: true, Content: openapi.JSON(gen.Schema(r.Request))}
+		}
+
+		status := r.SuccessStatus
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &openapi.Response{Description: http.StatusText(status)}
+		if r.Response != nil {
+			success.Content = openapi.JSON(gen.Schema(r.Response))
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		for _, code := range r.Errors {
+			op.Responses[strconv.Itoa(code)] = textError(code)
+		}
+		if r.Request != nil || len(r.Params) > 0 {
+			op.Responses["400"] = &openapi.Response{
+				Description: "The request failed validation, fields lists every failing field.",
+				Content:     openapi.JSON(errorSchema),
+			}
+		}
+		if r.Request != nil && routeBodyLimit(r, settings.MaxBodyBytes) > 0 {
+			op.Responses["413"] = textError(http.StatusRequestEntityTooLarge)
+		}
+		if r.Idempotent {
+			minLen, maxLen := 1, idempotency.MaxKeyLength
+			op.Parameters = append(op.Parameters, openapi.Parameter{
+				Name:        idempotency.Header,
+				In:          "header",
+				Description: "Repeating a key replays the first successful response, with Idempotent-Replayed: true.",
+				Schema:      &openapi.Schema{Type: "string", MinLength: &minLen, MaxLength: &maxLen},
+			})
+			if _, ok := op.Responses["400"]; !ok {
+				op.Responses["400"] = textError(http.StatusBadRequest)
+			}
+			inProgress := textError(http.StatusConflict)
+			inProgress.Description = "A request with the same Idempotency-Key is still being processed."
+			op.Responses["409"] = inProgress
+			mismatch := textError(http.StatusUnprocessableEntity)
+			mismatch.Description = "The Idempotency-Key was used for a different request."
+			op.Responses["422"] = mismatch
+		}
+		if r.Cache.Enabled() {
+			success.Headers = map[string]*openapi.Header{
+				"ETag":          {Description: "Entity tag of the body, for If-None-Match.", Schema: &openapi.Schema{Type: "string"}},
+				"Last-Modified": {Description: "When the response was produced, for If-Modified-Since.", Schema: &openapi.Schema{Type: "string"}},
+			}
+			op.Responses["304"] = &openapi.Response{Description: "Not Modified, the client's copy is current."}
+		}
+		if srv.requiresAuth(r) {
+			op.Security = security
+			op.Responses["401"] = textError(http.StatusUnauthorized)
+		}
+		if globalIPs.Enabled() || routeIPList(r, routeAllow, routeDeny).Enabled() {
+			forbidden := textError(http.StatusForbidden)
+			forbidden.Description = "The client address is outside the allowed ranges."
+			op.Responses["403"] = forbidden
+		}
+		if routeRateLimit(r, overrides).Enabled() {
+			limited := textError(http.StatusTooManyRequests)
+			limited.Headers = map[string]*openapi.Header{
+				"Retry-After": {Description: "Seconds until the next request is allowed.", Schema: &openapi.Schema{Type: "integer"}},
+			}
+			op.Responses["429"] = limited
+		}
+		if routeTimeout(r, defaultTimeout) > 0 {
+			op.Responses["504"] = &openapi.Response{
+				Description: "The request ran past the route's timeout.",
+				Content:     openapi.JSON(errorSchema),
+			}
+		}
+		internal := textError(http.StatusInternalServerError)
+		internal.Description = "Internal Server Error, as JSON when the handler panicked."
+		internal.Content["application/json"] = openapi.MediaType{Schema: errorSchema}
+		op.Responses["500"] = internal
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = openapi.PathItem{}
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(r.Method)] = op
+	}
+
+	doc.Components.Schemas = gen.Schemas()
+	return doc
+}
+
+// textError is an error answered with the plain text status of WrapHandler
+func textError(status int) *openapi.Response {
+	return &openapi.Response{
+		Description: http.StatusText(status),
+		Content:     map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}},
+	}
+}
+
+// openAPIHandler serves the OpenAPI document of routes, read when a request
+// comes in so that the route serving it can be part of the list
+func (srv *Server) openAPIHandler(routes *Routes) HandlerFuncWithError {
+	return func(c *gin.Context) error {
+		c.JSON(http.StatusOK, srv.openAPIDocument(*routes))
+		return nil
+	}
+}
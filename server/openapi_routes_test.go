@@ -0,0 +1,136 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/openapi"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestOpenAPIRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// The document is built from the settings the router runs with
+	var settings types.AppSettings
+	settings.Timeout = 15
+
+	s := store.NewStorage(db)
+	h := handlers.NewHandler(s, false, defaultTemplates, "test", "dev")
+	router := newTestRouter(t, h, s, settings)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a JSON document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected OpenAPI 3.1.0, got %q", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/pong/{argument}"]; ok {
+		t.Errorf("Expected no GET /pong/{argument}")
+	}
+
+	pong := doc.Paths["/pong"]["post"]
+	if pong == nil {
+		t.Fatalf("Expected POST /pong in %v", doc.Paths)
+	}
+	if pong.RequestBody == nil || len(pong.Security) == 0 {
+		t.Errorf("Expected POST /pong to take a body and need auth")
+	}
+	for _, status := range []string{"200", "400", "401", "429", "500", "504"} {
+		if pong.Responses[status] == nil {
+			t.Errorf("Expected a %s response for POST /pong", status)
+		}
+	}
+	if got := pong.Responses["200"].Content["application/json"].Schema.Ref; got != "#/components/schemas/PongResponse" {
+		t.Errorf("Expected the PongResponse schema, got %q", got)
+	}
+
+	if got := pong.RequestBody.Content["application/json"].Schema.Ref; got != "#/components/schemas/PongRequest" {
+		t.Errorf("Expected the PongRequest schema, got %q", got)
+	}
+	if text := doc.Components.Schemas["PongRequest"].Properties["text"]; text == nil || text.MaxLength == nil || *text.MaxLength != 200 {
+		t.Errorf("Expected the binding rules in the PongRequest schema, got %+v", text)
+	}
+	logs := doc.Paths["/logs/{from}/{to}"]["get"]
+	if logs == nil || logs.Parameters[0].Schema.Format != "date" || logs.Responses["400"] == nil {
+		t.Errorf("Expected GET /logs to take dates, got %+v", logs)
+	}
+
+	ping := doc.Paths["/ping/{argument}"]["get"]
+	if ping == nil || len(ping.Parameters) != 1 || ping.Parameters[0].Name != "argument" || ping.Parameters[0].In != "path" {
+		t.Fatalf("Expected GET /ping/{argument} with a path parameter, got %+v", ping)
+	}
+	if len(ping.Security) != 0 || ping.Responses["401"] != nil || ping.Responses["429"] != nil {
+		t.Errorf("Expected GET /ping to need no auth and have no rate limit")
+	}
+
+	run := doc.Paths["/admin/jobs/{name}/run"]["post"]
+	if run == nil || run.Responses["202"] == nil || run.Responses["409"] == nil || run.Tags[0] != "admin" {
+		t.Errorf("Expected the admin RunJob operation with a 202 and a 409, got %+v", run)
+	}
+	if _, ok := doc.Components.Schemas["UsageLog"]; !ok {
+		t.Errorf("Expected the UsageLog schema")
+	}
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/docs", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `spec-url="/openapi.json"`) {
+		t.Errorf("Expected the docs page to load /openapi.json, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `src="/assets/js/redoc.standalone.js"`) {
+		t.Errorf("Expected the docs page to load Redoc from /assets")
+	}
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/assets/js/redoc.standalone.js", nil)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Redoc") {
+		t.Errorf("Expected the embedded Redoc bundle on /assets, got %d", w.Code)
+	}
+}
+
+func TestOpenAPIPath(t *testing.T) {
+	path, params := openAPIPath("/logs/:from/:to", nil)
+	if path != "/logs/{from}/{to}" || len(params) != 2 || params[1].Name != "to" {
+		t.Errorf("Unexpected path %q and parameters %+v", path, params)
+	}
+	if path, _ := openAPIPath("/assets/*filepath", nil); path != "/assets/{filepath}" {
+		t.Errorf("Unexpected path %q", path)
+	}
+}
+
+func TestOpenAPIRequireAuth(t *testing.T) {
+	srv := New(WithGroup("/internal", func(*Server) Routes {
+		return Routes{{Name: "Report", Method: "GET", Pattern: "/report"}}
+	}, RequireAuth))
+	srv.handler = handlers.NewHandler(nil, false, defaultTemplates, "test", "dev")
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		t.Fatalf("Failed to build routes: %v", err)
+	}
+
+	report := srv.openAPIDocument(routes).Paths["/internal/report"]["get"]
+	if report == nil || len(report.Security) == 0 || report.Responses["401"] == nil {
+		t.Errorf("Expected RequireAuth in the group to show as auth, got %+v", report)
+	}
+}
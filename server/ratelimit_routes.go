@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/ratelimit"
+)
+
+// parseRateLimits reads RATE_LIMITS, a comma separated list of Route=limit pairs like "Pong=10/m:token,GetLogs=off"
+func parseRateLimits(spec string) (map[string]ratelimit.Limit, error) {
+	limits := map[string]ratelimit.Limit{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate limit %q must look like Route=10/m", part)
+		}
+		l, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[strings.TrimSpace(name)] = l
+	}
+	return limits, nil
+}
+
+// routeRateLimit returns the limit a route runs with, the configured override wins over the route's own
+func routeRateLimit(r Route, overrides map[string]ratelimit.Limit) ratelimit.Limit {
+	if l, ok := overrides[r.Name]; ok {
+		return l
+	}
+	return r.RateLimit
+}
+
+// preAuthFactor loosens the route's limit for the per address limit in front
+// of auth, the clients behind a NAT or proxy share an address
+const preAuthFactor = 10
+
+// preAuthBucket sets the buckets of the per address limit apart from the
+// ones of the limit by token, which counts unverified clients by address too
+const preAuthBucket = ":preauth"
+
+// preAuthLimit returns the per address limit that runs in front of auth on a
+// route limited by token, preAuthFactor times the route's own limit unless
+// RATE_LIMIT_PRE_AUTH sets one.
+func preAuthLimit(l ratelimit.Limit, spec string) ratelimit.Limit {
+	if spec != "" {
+		// validateSettings rejects a broken RATE_LIMIT_PRE_AUTH
+		l, _ = ratelimit.ParseLimit(spec)
+	} else {
+		l.Requests *= preAuthFactor
+	}
+	l.By = ratelimit.ByIP
+	return l
+}
+
+// RateLimitMiddleware answers 429 once a client has used up the route's limit
+func RateLimitMiddleware(s ratelimit.Store, route string, l ratelimit.Limit) Middleware {
+	policy := fmt.Sprintf("%d;w=%d", l.Requests, int(math.Ceil(l.Per.Seconds())))
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			res, err := s.Allow(c.Request.Context(), rateLimitKey(c, route, l.By), l)
+			if err != nil {
+				// Don't take the service down with the rate limit backend
+				slog.ErrorContext(c.Request.Context(), "Rate limit check failed", "route", route, "error", err)
+				return inner(c)
+			}
+
+			c.Header("RateLimit-Policy", policy)
+			c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			if !res.Allowed {
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				return httperror.ReturnWithHTTPStatus(
+					fmt.Errorf("rate limit of %s exceeded", l),
+					http.StatusTooManyRequests,
+				)
+			}
+			return inner(c)
+		}
+	}
+}
+
+// rateLimitKey picks the bucket for a request. Only a token or certificate
+// verified by AuthMiddleware gets a bucket of its own, other clients are
+// counted by address.
+func rateLimitKey(c *gin.Context, route string, by ratelimit.By) string {
+	switch by {
+	case ratelimit.ByRoute:
+		return "route:" + route
+	case ratelimit.ByToken:
+		if client := c.GetString(verifiedClientKey); client != "" {
+			return client + ":" + route
+		}
+	}
+	return "ip:" + route + ":" + c.ClientIP()
+}
+
+// verifiedClient names the client AuthMiddleware let through. Only a hash of
+// the token is kept, the rate limit store may be a shared database.
+func verifiedClient(identity, token string) string {
+	if identity != "" {
+		return "cert:" + identity
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:16])
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/ratelimit"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ok := func(c *gin.Context) error {
+		c.String(http.StatusOK, "ok")
+		return nil
+	}
+	// Stands in for AuthMiddleware, tokens starting with "valid" are verified
+	verify := func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if token := c.GetHeader("Authorization"); strings.HasPrefix(token, "valid") {
+				c.Set(verifiedClientKey, verifiedClient("", token))
+			}
+			return inner(c)
+		}
+	}
+	limit := ratelimit.Limit{Requests: 2, Per: time.Minute, By: ratelimit.ByToken}
+	router := gin.New()
+	router.GET("/limited", WrapHandler(verify(RateLimitMiddleware(ratelimit.NewMemoryStore(), "Limited", limit)(ok))))
+
+	call := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/limited", nil)
+		req.Header.Set("Authorization", token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := call("valid-first"); w.Code != http.StatusOK {
+			t.Fatalf("Expected request %d to pass, got %d", i+1, w.Code)
+		}
+	}
+
+	w := call("valid-first")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("Expected RateLimit-Remaining 0, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "2;w=60" {
+		t.Errorf("Expected RateLimit-Policy 2;w=60, got %q", got)
+	}
+
+	if w := call("valid-second"); w.Code != http.StatusOK {
+		t.Errorf("Expected another token to have its own limit, got %d", w.Code)
+	}
+
+	// Unverified tokens share the bucket of the client address
+	if w := call("random-1"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the first unverified request to pass, got %d", w.Code)
+	}
+	call("random-2")
+	if w := call("random-3"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected unverified tokens to share a limit, got %d", w.Code)
+	}
+}
+
+func TestRateLimitBeforeAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	srv := New(WithRoutes(func(*Server) Routes {
+		return Routes{{
+			Name:      "Report",
+			Method:    "GET",
+			Pattern:   "/report",
+			UseAuth:   true,
+			RateLimit: ratelimit.Limit{Requests: 1, Per: time.Minute, By: ratelimit.ByToken},
+			HandlerFunc: func(c *gin.Context) error {
+				c.String(http.StatusOK, "ok")
+				return nil
+			},
+		}}
+	}))
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		t.Fatalf("Failed to build routes: %v", err)
+	}
+	srv.settings.AuthToken = "secret-token"
+	srv.settings.RateLimitPreAuth = "2/m"
+	router := srv.newPublicRouter(routes, nil)
+
+	call := func(addr, token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/report", nil)
+		req.RemoteAddr = addr + ":1234"
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := call("192.0.2.1", "secret-token"); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if code := call("192.0.2.1", "secret-token"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the token limit to apply, got %d", code)
+	}
+
+	// Every request counts against the address, a new token per request
+	// does not get a new bucket
+	for i := 0; i < 2; i++ {
+		if code := call("192.0.2.2", fmt.Sprintf("guess-%d", i)); code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401 for guess %d, got %d", i, code)
+		}
+	}
+	if code := call("192.0.2.2", "secret-token"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the address limit to stop the valid token too, got %d", code)
+	}
+}
+
+func TestRateLimitByTokenCountsOnce(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, useAuth := range []bool{false, true} {
+		srv := New(WithRoutes(func(*Server) Routes {
+			return Routes{{
+				Name:      "Report",
+				Method:    "GET",
+				Pattern:   "/report",
+				UseAuth:   useAuth,
+				RateLimit: ratelimit.Limit{Requests: 2, Per: time.Minute, By: ratelimit.ByToken},
+				HandlerFunc: func(c *gin.Context) error {
+					c.String(http.StatusOK, "ok")
+					return nil
+				},
+			}}
+		}))
+		routes, err := srv.buildRoutes()
+		if err != nil {
+			t.Fatalf("Failed to build routes: %v", err)
+		}
+		router := srv.newPublicRouter(routes, nil)
+
+		// Without a token the limit by token counts the address, the looser
+		// limit in front of auth uses buckets of its own
+		var codes []int
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/report", nil))
+			codes = append(codes, w.Code)
+		}
+		if codes[0] != http.StatusOK || codes[1] != http.StatusOK || codes[2] != http.StatusTooManyRequests {
+			t.Errorf("Expected 200, 200, 429 with UseAuth %v, got %v", useAuth, codes)
+		}
+	}
+}
+
+func TestRateLimitPreAuthDefault(t *testing.T) {
+	l := preAuthLimit(ratelimit.Limit{Requests: 5, Per: time.Minute, By: ratelimit.ByToken}, "")
+	if l.Requests != 5*preAuthFactor || l.By != ratelimit.ByIP {
+		t.Errorf("Expected a looser limit by address, got %+v", l)
+	}
+	if l := preAuthLimit(ratelimit.Limit{Requests: 5, Per: time.Minute}, "off"); l.Enabled() {
+		t.Errorf("Expected off to disable the limit, got %+v", l)
+	}
+}
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := parseRateLimits("Pong=10/m:ip, GetLogs=off")
+	if err != nil {
+		t.Fatalf("parseRateLimits failed: %v", err)
+	}
+
+	pong := Route{Name: "Pong", RateLimit: ratelimit.Limit{Requests: 60, Per: time.Minute}}
+	if got := routeRateLimit(pong, limits); got.Requests != 10 || got.By != ratelimit.ByIP {
+		t.Errorf("Expected the override for Pong, got %+v", got)
+	}
+	logs := Route{Name: "GetLogs", RateLimit: ratelimit.Limit{Requests: 30, Per: time.Minute}}
+	if got := routeRateLimit(logs, limits); got.Enabled() {
+		t.Errorf("Expected GetLogs to be disabled, got %+v", got)
+	}
+	ping := Route{Name: "Ping"}
+	if got := routeRateLimit(ping, limits); got.Enabled() {
+		t.Errorf("Expected Ping to have no limit, got %+v", got)
+	}
+
+	if _, err := parseRateLimits("Pong"); err == nil {
+		t.Errorf("Expected a missing limit to fail")
+	}
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/metrics"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// panicError is a panic recovered in a handler, returned as a 500
+type panicError struct {
+	value any
+	stack []byte
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// PanicReport describes a recovered panic to the alert hook
+type PanicReport struct {
+	Route     string    `json:"route"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Value     string    `json:"value"`
+	Stack     string    `json:"stack"`
+	RequestID string    `json:"request_id,omitempty"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// RecoveryMiddleware turns a panic in the wrapped handler into a 500 error, so
+// that the logger still writes the request log, with the stack attached.
+// alert, when not nil, is sent a report of every panic. It runs on the
+// request's goroutine and must not block.
+func RecoveryMiddleware(m *metrics.Metrics, alert func(PanicReport), route string) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) (err error) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					// Deliberate aborts are left to net/http
+					panic(v)
+				}
+				pe := &panicError{value: v, stack: debug.Stack()}
+				reportPanic(c, m, alert, route, pe)
+				err = httperror.ReturnWithHTTPStatus(pe, http.StatusInternalServerError)
+			}()
+			return inner(c)
+		}
+	}
+}
+
+// PanicRecovery replaces gin.Recovery for panics outside the route handlers,
+// like in the global middleware. routeNames maps "METHOD pattern" to the route name.
+func PanicRecovery(m *metrics.Metrics, alert func(PanicReport), routeNames map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			route, ok := routeNames[c.Request.Method+" "+c.FullPath()]
+			if !ok {
+				route = c.FullPath()
+			}
+			reportPanic(c, m, alert, route, &panicError{value: v, stack: debug.Stack()})
+			writePanicResponse(c)
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// reportPanic logs, counts and traces a panic and fires the alert hook
+func reportPanic(c *gin.Context, m *metrics.Metrics, alert func(PanicReport), route string, pe *panicError) {
+	ctx := c.Request.Context()
+	slog.ErrorContext(ctx, "Recovered from panic", "route", route, "panic", fmt.Sprint(pe.value), "stack", string(pe.stack))
+	m.Panic(route)
+	trace.SpanFromContext(ctx).RecordError(pe, trace.WithAttributes(semconv.ExceptionStacktrace(string(pe.stack))))
+
+	if alert != nil {
+		alert(PanicReport{
+			Route:     route,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Value:     fmt.Sprint(pe.value),
+			Stack:     string(pe.stack),
+			RequestID: requestid.FromContext(ctx),
+			TraceID:   tracing.TraceID(ctx),
+			Time:      time.Now(),
+		})
+	}
+}
+
+// writePanicResponse answers with a JSON 500 that points at the request log,
+// unless the handler already started the response
+func writePanicResponse(c *gin.Context) {
+	if c.Writer.Written() {
+		return
+	}
+	c.JSON(http.StatusInternalServerError, errorResponse{
+		Status:    http.StatusInternalServerError,
+		Error:     http.StatusText(http.StatusInternalServerError),
+		RequestID: requestid.FromContext(c.Request.Context()),
+	})
+}
+
+// recoveredPanic returns the panic behind err, if any
+func recoveredPanic(err error) (*panicError, bool) {
+	var pe *panicError
+	ok := errors.As(err, &pe)
+	return pe, ok
+}
+
+// panicAlertQueue is how many panic alerts wait for the webhook, more are dropped
+const panicAlertQueue = 16
+
+// newPanicWebhook returns an alert hook that posts the report as JSON to url.
+// Reports are queued and posted one at a time, when the queue is full, e.g.
+// during a panic storm or while the webhook is down, they are dropped.
+func newPanicWebhook(url string) func(PanicReport) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	queue := make(chan PanicReport, panicAlertQueue)
+	go func() {
+		for r := range queue {
+			postPanicReport(client, url, r)
+		}
+	}()
+	return func(r PanicReport) {
+		select {
+		case queue <- r:
+		default:
+			slog.Warn("Dropped panic alert, the webhook is behind", "route", r.Route, "request_id", r.RequestID)
+		}
+	}
+}
+
+// postPanicReport posts a report to the webhook at url
+func postPanicReport(client *http.Client, url string, r PanicReport) {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		slog.Error("Failed to send panic alert", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		slog.Error("Failed to send panic alert", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		slog.Error("Panic alert rejected", "status", resp.StatusCode)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/metrics"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+	m := metrics.New("test")
+
+	alerts := make(chan PanicReport, 1)
+	alert := func(r PanicReport) { alerts <- r }
+
+	boom := func(c *gin.Context) error {
+		var items []string
+		_ = items[3]
+		return nil
+	}
+
+	router := gin.New()
+	router.Use(PanicRecovery(m, nil, map[string]string{}))
+	router.Use(RequestIDMiddleware())
+	router.GET("/boom", WrapHandler(LoggerMiddleware(sink)(RecoveryMiddleware(m, alert, "Boom")(boom))))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/boom", nil)
+	req.Header.Set(requestid.Header, "panic-1")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Expected a JSON body, got %q", w.Body.String())
+	}
+	if body["request_id"] != "panic-1" || strings.Contains(w.Body.String(), "index out of range") {
+		t.Errorf("Unexpected body %s", w.Body.String())
+	}
+
+	if saved.Status != http.StatusInternalServerError || !strings.Contains(saved.Error, "index out of range") {
+		t.Errorf("Expected the panic in the request log, got status %d and error %q", saved.Status, saved.Error)
+	}
+	if !strings.Contains(saved.Stack, "recovery_test.go") {
+		t.Errorf("Expected the stack to point at the panic, got %q", saved.Stack)
+	}
+
+	r := <-alerts
+	if r.Route != "Boom" || r.RequestID != "panic-1" || !strings.Contains(r.Value, "index out of range") {
+		t.Errorf("Unexpected alert %+v", r)
+	}
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `template_service_panics_total{route="Boom"} 1`) {
+		t.Errorf("Expected the panic to be counted")
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New("test")
+
+	router := gin.New()
+	router.Use(PanicRecovery(m, nil, map[string]string{"GET /boom": "Boom"}))
+	router.Use(func(c *gin.Context) { panic("middleware") })
+	router.GET("/boom", func(c *gin.Context) {})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/boom", nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), `"status":500`) {
+		t.Errorf("Expected a JSON 500, got %d %q", w.Code, w.Body.String())
+	}
+
+	t.Run("abort handler", func(t *testing.T) {
+		router := gin.New()
+		router.Use(PanicRecovery(m, nil, map[string]string{}))
+		router.GET("/abort", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Errorf("Expected http.ErrAbortHandler to be passed on, got %v", v)
+			}
+		}()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	})
+}
+
+func TestPanicWebhookQueue(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan PanicReport, 2*panicAlertQueue)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var report PanicReport
+		json.NewDecoder(r.Body).Decode(&report)
+		received <- report
+	}))
+	defer hook.Close()
+
+	// The webhook is stuck, alerts past the queue are dropped instead of piling up
+	alert := newPanicWebhook(hook.URL)
+	for i := 0; i < 2*panicAlertQueue; i++ {
+		alert(PanicReport{Route: "Boom"})
+	}
+	close(release)
+
+	timeout := time.After(5 * time.Second)
+	for n := 0; n < panicAlertQueue; n++ {
+		select {
+		case <-received:
+		case <-timeout:
+			t.Fatalf("Expected %d alerts, got %d", panicAlertQueue, n)
+		}
+	}
+	select {
+	case <-received:
+		// The worker may have taken one off the queue before it filled up
+	case <-time.After(100 * time.Millisecond):
+	}
+	select {
+	case <-received:
+		t.Errorf("Expected the alerts past the queue to be dropped")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
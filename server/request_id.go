@@ -0,0 +1,22 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/tracing"
+)
+
+// RequestIDMiddleware accepts the client's X-Request-ID or traceparent, or
+// generates an ID, puts it in the request context and echoes it back
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := requestid.FromRequest(c.Request)
+		if traceID := tracing.TraceID(c.Request.Context()); traceID != "" && id != c.GetHeader(requestid.Header) {
+			// Without an ID from the client, reuse the trace started for the request
+			id = traceID
+		}
+		c.Request = c.Request.WithContext(requestid.NewContext(c.Request.Context(), id))
+		c.Header(requestid.Header, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/types"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+	var seen string
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/", WrapHandler(LoggerMiddleware(sink)(func(c *gin.Context) error {
+		seen = requestid.FromContext(c.Request.Context())
+		c.String(http.StatusOK, "ok")
+		return nil
+	})))
+
+	t.Run("Accepted from the client", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set(requestid.Header, "abc-123")
+		router.ServeHTTP(w, req)
+
+		if got := w.Header().Get(requestid.Header); got != "abc-123" {
+			t.Errorf("Expected the request ID to be echoed, got %q", got)
+		}
+		if seen != "abc-123" || saved.RequestID != "abc-123" {
+			t.Errorf("Expected the handler and the log to see abc-123, got %q and %q", seen, saved.RequestID)
+		}
+	})
+
+	t.Run("Generated", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+		id := w.Header().Get(requestid.Header)
+		if id == "" || id != saved.RequestID {
+			t.Errorf("Expected a generated ID in the response and the log, got %q and %q", id, saved.RequestID)
+		}
+	})
+}
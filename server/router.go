@@ -0,0 +1,613 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cache"
+	"github.com/johansundell/template-service/compression"
+	"github.com/johansundell/template-service/cors"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/idempotency"
+	"github.com/johansundell/template-service/ipfilter"
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/tracing"
+	"github.com/johansundell/template-service/types"
+	"github.com/johansundell/template-service/utils"
+	"github.com/johansundell/template-service/validation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type HandlerFuncWithError func(*gin.Context) error
+
+// Middleware wraps a handler, like the ones built from the settings of a Route
+type Middleware func(HandlerFuncWithError) HandlerFuncWithError
+
+// Chain composes middleware into one, the first one runs outermost
+func Chain(mw ...Middleware) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		for i := len(mw) - 1; i >= 0; i-- {
+			inner = mw[i](inner)
+		}
+		return inner
+	}
+}
+
+// RouteListener selects which listener a route is served on
+type RouteListener int
+
+const (
+	// PublicListener serves the business routes on PORT
+	PublicListener RouteListener = iota
+	// AdminListener serves operational routes on ADMIN_PORT, or on PORT when no admin listener is configured
+	AdminListener
+)
+
+// Route struct for the service
+type Route struct {
+	Name        string
+	Method      string
+	Pattern     string
+	HandlerFunc HandlerFuncWithError
+	UseLogger   bool
+	UseAuth     bool
+	Listener    RouteListener
+	// Timeout bounds the handler through the request context. Zero uses
+	// TIMEOUT, NoTimeout disables it for streaming routes.
+	Timeout time.Duration
+	// RateLimit limits how often a client may call the route, RATE_LIMITS overrides it
+	RateLimit ratelimit.Limit
+	// CORS replaces the CORS_* policy for the route, an empty policy disables CORS
+	CORS *cors.Policy
+	// Idempotent replays the stored response to requests repeating an
+	// Idempotency-Key, for IDEMPOTENCY_TTL hours
+	Idempotent bool
+	// Cache serves GET responses from the response cache for the policy's TTL
+	Cache cache.Policy
+	// IPs restricts the client addresses allowed to call the route, on top of
+	// IP_ALLOW and IP_DENY. ROUTE_IP_ALLOW and ROUTE_IP_DENY override it.
+	IPs ipfilter.List
+	// DuringMaintenance keeps a public route working in maintenance mode, admin routes always do
+	DuringMaintenance bool
+	// Middleware wraps the handler, the first one runs outermost. It runs
+	// after the middleware of WithMiddleware and of the route's group and
+	// UseAuth, and before the rate limit by token, the cache, the timeout and
+	// validation. RequireAuth runs auth at its place in the list.
+	Middleware []Middleware
+	// groupMiddleware are the middleware of the route's group, they run
+	// between the ones of WithMiddleware and the route's own
+	groupMiddleware []Middleware
+
+	// Request is a value of the JSON body type the route accepts, nil when it
+	// takes none. Bodies are decoded into it and checked against its binding
+	// tags before the handler runs.
+	Request any
+	// Params declares the types and rules of path and query parameters, path
+	// parameters left out are plain strings
+	Params []validation.Param
+	// MaxBodyBytes limits the request body, zero uses MAX_BODY_BYTES and NoBodyLimit lifts the limit
+	MaxBodyBytes int64
+
+	// Summary, Response, SuccessStatus and Errors describe the route in /openapi.json
+	Summary string
+	// Response is a value of the JSON body type the route answers with
+	Response any
+	// SuccessStatus is the status of a successful response, zero means 200
+	SuccessStatus int
+	// Errors are the statuses the handler returns itself, the ones caused by
+	// auth, rate limits and timeouts are added from the route's settings
+	Errors []int
+}
+
+// Routes for the servcie web handlers
+type Routes []Route
+
+// newPublicRouter creates the web handler for the public listener. Admin
+// routes are included unless a separate admin listener is configured.
+func (srv *Server) newPublicRouter(routes Routes, s store.LogSink) *gin.Engine {
+	router := srv.newRouter(routes, s, func(r Route) bool {
+		return r.Listener == PublicListener || srv.settings.AdminPort == ""
+	})
+
+	// Static files
+	assets := gin.WrapH(http.StripPrefix("/assets", compression.FileServer(srv.staticFiles(srv.settings.UseFileSystem))))
+	router.GET("/assets/*filepath", assets)
+	router.HEAD("/assets/*filepath", assets)
+
+	return router
+}
+
+// newAdminRouter creates the web handler for the admin listener
+func (srv *Server) newAdminRouter(routes Routes, s store.LogSink) *gin.Engine {
+	return srv.newRouter(routes, s, func(r Route) bool {
+		return r.Listener == AdminListener
+	})
+}
+
+func (srv *Server) newRouter(routes Routes, s store.LogSink, include func(Route) bool) *gin.Engine {
+	settings := srv.settings
+	gin.SetMode(gin.ReleaseMode) // Set mode before creating the router
+
+	//router := gin.Default()
+	router := gin.New()
+	// Lets handlers pass the gin context on to queries, keeping the request's trace and deadline
+	router.ContextWithFallback = true
+	// Filled in below, the middleware reads it per request
+	routeNames := map[string]string{}
+	// ClientIPMiddleware resolves the client address behind TRUSTED_PROXIES, gin must not trust any on its own
+	router.SetTrustedProxies(nil)
+	router.Use(PanicRecovery(srv.metrics, srv.panicAlert, routeNames))
+	router.Use(ClientIPMiddleware(clientIPResolver(settings), globalIPList(settings)))
+	router.Use(TracingMiddleware(routeNames))
+	router.Use(RequestIDMiddleware())
+	// RequireAuth finds the auth of this router on the context
+	auth := AuthMiddleware(settings.AuthToken, clientSubjects(settings))
+	router.Use(func(c *gin.Context) { c.Set(authMiddlewareKey, auth) })
+	if settings.Compression {
+		router.Use(CompressionMiddleware(compressionConfig(settings)))
+	}
+	router.Use(srv.ginMiddleware...)
+
+	rc := routerConfig{
+		settings: settings,
+		sink:     s,
+		auth:     auth,
+		limits:   srv.rateLimits,
+		keys:     srv.idempotencyStore,
+		cors:     corsPolicy(settings),
+	}
+	if rc.limits == nil {
+		rc.limits = ratelimit.NewMemoryStore()
+	}
+	if rc.keys == nil {
+		rc.keys = idempotency.NewMemoryStore()
+	}
+	// setup rejects a broken RATE_LIMITS before the routers are built
+	rc.overrides, _ = parseRateLimits(settings.RateLimits)
+	rc.routeAllow, _ = parseRouteIPLists(settings.IPFilterSettings.RouteAllow)
+	rc.routeDeny, _ = parseRouteIPLists(settings.IPFilterSettings.RouteDeny)
+	rc.maintenanceAllow = splitList(settings.MaintenanceAllow)
+
+	version := versionHeader(srv.version)
+	byPattern := map[string][]Route{}
+	var patterns []string
+
+	for _, route := range routes {
+		if !include(route) {
+			continue
+		}
+		if _, ok := byPattern[route.Pattern]; !ok {
+			patterns = append(patterns, route.Pattern)
+		}
+		byPattern[route.Pattern] = append(byPattern[route.Pattern], route)
+		routeNames[route.Method+" "+route.Pattern] = route.Name
+
+		route.HandlerFunc = srv.middlewareChain(route, rc).wrap(route.HandlerFunc)
+
+		// Convert to Gin Handler and register
+		router.Handle(route.Method, route.Pattern, version, WrapHandler(route.HandlerFunc))
+	}
+
+	// Answer CORS preflight requests for every path that doesn't handle OPTIONS itself
+	for _, pattern := range patterns {
+		routes := byPattern[pattern]
+		if slices.ContainsFunc(routes, func(r Route) bool { return r.Method == http.MethodOptions }) {
+			continue
+		}
+		router.OPTIONS(pattern, version, WrapHandler(preflightHandler(routes, rc.cors)))
+	}
+
+	return router
+}
+
+// routerConfig is what newRouter reads from the settings once for all its routes
+type routerConfig struct {
+	settings         types.AppSettings
+	sink             store.LogSink
+	auth             Middleware
+	limits           ratelimit.Store
+	keys             idempotency.Store
+	cors             cors.Policy
+	overrides        map[string]ratelimit.Limit
+	routeAllow       map[string][]netip.Prefix
+	routeDeny        map[string][]netip.Prefix
+	maintenanceAllow []string
+}
+
+// namedMiddleware is an entry of the chain around a route
+type namedMiddleware struct {
+	name string
+	mw   Middleware
+}
+
+// middlewareChain is the middleware around a route, the first entry outermost
+type middlewareChain []namedMiddleware
+
+// names returns the names of the entries, outermost first
+func (mc middlewareChain) names() []string {
+	names := make([]string, len(mc))
+	for i, e := range mc {
+		names[i] = e.name
+	}
+	return names
+}
+
+// wrap wraps inner in the entries
+func (mc middlewareChain) wrap(inner HandlerFuncWithError) HandlerFuncWithError {
+	mw := make([]Middleware, len(mc))
+	for i, e := range mc {
+		mw[i] = e.mw
+	}
+	return Chain(mw...)(inner)
+}
+
+// isAuthEntry reports whether e is the auth of the chain
+func isAuthEntry(e namedMiddleware) bool {
+	return e.name == "auth"
+}
+
+// middlewareChain builds the chain around a route from its settings. Entries
+// the route doesn't use are left out.
+func (srv *Server) middlewareChain(route Route, rc routerConfig) middlewareChain {
+	var mc middlewareChain
+	add := func(name string, mw Middleware) {
+		mc = append(mc, namedMiddleware{name, mw})
+	}
+
+	add("metrics", MetricsMiddleware(srv.metrics, route.Name))
+	if policy := routeCORS(route, rc.cors); policy.Enabled() {
+		add("cors", CORSMiddleware(policy))
+	}
+	// Outside the logger so that it reads no more than the limit
+	if limit := routeBodyLimit(route, rc.settings.MaxBodyBytes); limit > 0 {
+		add("bodyLimit", BodyLimitMiddleware(limit))
+	}
+	if route.UseLogger {
+		add("logger", LoggerMiddleware(rc.sink))
+	}
+	// Inside the logger so that panics are logged
+	add("recovery", RecoveryMiddleware(srv.metrics, srv.panicAlert, route.Name))
+	if srv.maintenanceMode != nil && !maintenanceAllowed(route, rc.maintenanceAllow) {
+		add("maintenance", MaintenanceMiddleware(srv.maintenanceMode, srv.handler.MaintenancePage))
+	}
+	// Before the rate limit so that refused clients use no tokens
+	if ips := routeIPList(route, rc.routeAllow, rc.routeDeny); ips.Enabled() {
+		add("ipFilter", IPFilterMiddleware(ips))
+	}
+	// The lists of WithMiddleware, the group and the route run in their
+	// declared order, RequireAuth in them is auth at its place. UseAuth adds
+	// auth in front of the route's own list, auth runs once.
+	var lists middlewareChain
+	addList := func(name string, mws []Middleware) {
+		for _, mw := range mws {
+			if isRequireAuth(mw) {
+				if !slices.ContainsFunc(lists, isAuthEntry) {
+					lists = append(lists, namedMiddleware{"auth", rc.auth})
+				}
+				continue
+			}
+			lists = append(lists, namedMiddleware{name, mw})
+		}
+	}
+	addList("global", srv.middleware)
+	addList("group", route.groupMiddleware)
+	if route.UseAuth && !slices.ContainsFunc(lists, isAuthEntry) {
+		lists = append(lists, namedMiddleware{"auth", rc.auth})
+	}
+	addList("route", route.Middleware)
+
+	// Right in front of auth so that failed attempts count against the client
+	// address, after the lists when there is no auth. Limits by token run
+	// after the lists, so that only verified clients get a bucket of their
+	// own, with a looser limit by address in front of auth.
+	authAt := slices.IndexFunc(lists, isAuthEntry)
+	if authAt < 0 {
+		authAt = len(lists)
+	}
+	limit := routeRateLimit(route, rc.overrides)
+	if limit.Enabled() && limit.By == ratelimit.ByToken {
+		if preAuth := preAuthLimit(limit, rc.settings.RateLimitPreAuth); authAt < len(lists) && preAuth.Enabled() {
+			lists = slices.Insert(lists, authAt, namedMiddleware{"rateLimit", RateLimitMiddleware(rc.limits, route.Name+preAuthBucket, preAuth)})
+		}
+	} else if limit.Enabled() {
+		lists = slices.Insert(lists, authAt, namedMiddleware{"rateLimit", RateLimitMiddleware(rc.limits, route.Name, limit)})
+	}
+	mc = append(mc, lists...)
+
+	if limit.Enabled() && limit.By == ratelimit.ByToken {
+		add("tokenRateLimit", RateLimitMiddleware(rc.limits, route.Name, limit))
+	}
+	// Inside auth so that only authorized clients see cached responses
+	if route.Cache.Enabled() && srv.responseCache != nil {
+		add("cache", CacheMiddleware(srv.responseCache, route.Name, route.Cache))
+	}
+
+	timeout := routeTimeout(route, time.Duration(rc.settings.Timeout)*time.Second)
+	if timeout > 0 {
+		add("timeout", TimeoutMiddleware(timeout))
+	}
+	add("validation", ValidationMiddleware(route))
+	// Inside validation so that invalid requests don't claim keys
+	if route.Idempotent {
+		lock := timeout
+		if lock <= 0 {
+			lock = idempotencyLock
+		}
+		ttl := time.Duration(rc.settings.IdempotencyTTL) * time.Hour
+		add("idempotency", IdempotencyMiddleware(rc.keys, route.Name, ttl, lock))
+	}
+	return mc
+}
+
+// RequireAuth checks AUTH_TOKEN like UseAuth does, for middleware lists. It
+// runs at its place in the list, so the middleware after it only sees
+// verified clients, and routes with it are described in /openapi.json as
+// needing auth.
+func RequireAuth(inner HandlerFuncWithError) HandlerFuncWithError {
+	return func(c *gin.Context) error {
+		auth, _ := c.Value(authMiddlewareKey).(Middleware)
+		if auth == nil {
+			// Only the routers set it, a route served without one is refused
+			return httperror.ReturnWithHTTPStatus(errors.New("authentication is not configured"), http.StatusInternalServerError)
+		}
+		return auth(inner)(c)
+	}
+}
+
+// authMiddlewareKey holds the AuthMiddleware of the router that serves the request
+const authMiddlewareKey = "authMiddleware"
+
+// isRequireAuth reports whether mw is RequireAuth. Middleware are functions,
+// so it is told apart by its code, the way gin names handlers.
+func isRequireAuth(mw Middleware) bool {
+	return reflect.ValueOf(mw).Pointer() == reflect.ValueOf(Middleware(RequireAuth)).Pointer()
+}
+
+// requiresAuth reports whether route checks AUTH_TOKEN, through UseAuth or
+// RequireAuth in one of its lists
+func (srv *Server) requiresAuth(route Route) bool {
+	return route.UseAuth || slices.ContainsFunc(slices.Concat(srv.middleware, route.groupMiddleware, route.Middleware), isRequireAuth)
+}
+
+// verifiedClientKey holds the client AuthMiddleware verified, rate limits by token count against it
+const verifiedClientKey = "verifiedClient"
+
+// AuthMiddleware returns a middleware that validates the Authorization header.
+// A client certificate verified by mutual TLS is accepted in place of the
+// token when its subject is one of subjects, "*" accepts any verified certificate.
+func AuthMiddleware(authToken string, subjects []string) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if identity := clientIdentity(c); identity != "" && subjectAllowed(identity, subjects) {
+				c.Set("clientIdentity", identity)
+				c.Set(verifiedClientKey, verifiedClient(identity, ""))
+				return inner(c)
+			}
+
+			if authToken == "" {
+				// If no auth token is configured, skip authentication
+				return inner(c)
+			}
+
+			authHeader := c.GetHeader("Authorization")
+			if authHeader == "" {
+				return httperror.ReturnWithHTTPStatus(
+					fmt.Errorf("missing authorization header"),
+					http.StatusUnauthorized,
+				)
+			}
+
+			// Support both "Bearer <token>" and plain "<token>" formats
+			var token string
+			if strings.HasPrefix(authHeader, "Bearer ") && len(authHeader) > 7 {
+				token = authHeader[7:]
+			} else {
+				token = authHeader
+			}
+
+			// Use constant time comparison to prevent timing attacks
+			if subtle.ConstantTimeCompare([]byte(token), []byte(authToken)) != 1 {
+				return httperror.ReturnWithHTTPStatus(
+					fmt.Errorf("invalid authorization token"),
+					http.StatusUnauthorized,
+				)
+			}
+
+			c.Set(verifiedClientKey, verifiedClient("", token))
+			return inner(c)
+		}
+	}
+}
+
+// staticFiles returns the assets, including any precompressed .gz and .br
+// files, with the default assets of the server package behind them
+func (srv *Server) staticFiles(useLocal bool) fs.FS {
+	defaults, err := fs.Sub(defaultAssets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	if useLocal {
+		return layeredFS{os.DirFS("assets"), defaults}
+	}
+	if srv.assets == nil {
+		return defaults
+	}
+
+	fsys, err := fs.Sub(srv.assets, "assets")
+	if err != nil {
+		panic(err)
+	}
+	return layeredFS{fsys, defaults}
+}
+
+// layeredFS opens a name from the first file system that has it
+type layeredFS []fs.FS
+
+func (l layeredFS) Open(name string) (fs.File, error) {
+	err := fs.ErrNotExist
+	for _, fsys := range l {
+		f, openErr := fsys.Open(name)
+		if !errors.Is(openErr, fs.ErrNotExist) {
+			return f, openErr
+		}
+		err = openErr
+	}
+	return nil, err
+}
+
+// errorResponse is the JSON body of validation errors, timeouts and recovered panics
+type errorResponse struct {
+	Status    int                     `json:"status"`
+	Error     string                  `json:"error"`
+	Detail    string                  `json:"detail,omitempty"`
+	Fields    []validation.FieldError `json:"fields,omitempty"`
+	RequestID string                  `json:"request_id,omitempty"`
+}
+
+// versionHeader sends the version of the service in X-Version
+func versionHeader(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Version", version)
+	}
+}
+
+func WrapHandler(inner HandlerFuncWithError) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := inner(c); err != nil {
+			if _, ok := recoveredPanic(err); ok {
+				writePanicResponse(c)
+				return
+			}
+			if verr, ok := validationFailed(err); ok {
+				c.JSON(http.StatusBadRequest, errorResponse{
+					Status:    http.StatusBadRequest,
+					Error:     httperror.StatusText(err),
+					Fields:    verr.Fields,
+					RequestID: requestid.FromContext(c.Request.Context()),
+				})
+				return
+			}
+			if timedOut(err) {
+				c.JSON(http.StatusGatewayTimeout, errorResponse{
+					Status: http.StatusGatewayTimeout,
+					Error:  httperror.StatusText(err),
+					Detail: errors.Unwrap(err).Error(),
+				})
+				return
+			}
+			c.String(httperror.HTTPStatus(err), httperror.StatusText(err))
+		}
+	}
+}
+
+func LoggerMiddleware(s store.LogSink) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			// Read the request body once
+			var requestBody []byte
+			if c.Request.Body != nil {
+				var readErr error
+				requestBody, readErr = io.ReadAll(c.Request.Body)
+				c.Request.Body.Close()
+
+				// Reset the request body so it can be read again. A read error, like
+				// a body over the limit, is passed on to the next reader.
+				var body io.Reader = bytes.NewBuffer(requestBody)
+				if readErr != nil {
+					body = io.MultiReader(body, errReader{readErr})
+				}
+				c.Request.Body = io.NopCloser(body)
+			}
+
+			// Wrap the original ResponseWriter with our Gin-compatible wrapper
+			blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+			c.Writer = blw
+
+			err := inner(c)
+
+			// Log the request/response
+			var status int
+			var errMsg string
+			if err != nil {
+				status = httperror.HTTPStatus(err)
+				errMsg = err.Error()
+			} else {
+				status = c.Writer.Status()
+				errMsg = ""
+			}
+
+			log := types.UsageLog{
+				Status:    status,
+				Method:    c.Request.Method,
+				Error:     errMsg,
+				Endpoint:  utils.GetUrl(c.Request, c.Request.URL.Path),
+				CreatedAt: time.Now(),
+				Response:  types.RawJSON(blw.body.String()),
+				Request:   types.RawJSON(requestBody),
+
+				ClientIP:       c.ClientIP(),
+				ClientIdentity: clientIdentity(c),
+				TimeoutMs:      c.GetDuration("requestTimeout").Milliseconds(),
+				TimedOut:       timedOut(err),
+				RequestID:      requestid.FromContext(c.Request.Context()),
+				TraceID:        tracing.TraceID(c.Request.Context()),
+				SpanContext:    trace.SpanContextFromContext(c.Request.Context()),
+			}
+			if pe, ok := recoveredPanic(err); ok {
+				log.Stack = string(pe.stack)
+			}
+
+			if len(requestBody) == 0 {
+				log.Request = types.RawJSON("{}")
+			}
+
+			attrs := []any{
+				"status", log.Status,
+				"method", log.Method,
+				"endpoint", log.Endpoint,
+				"client_ip", log.ClientIP,
+			}
+			if err != nil {
+				slog.WarnContext(c.Request.Context(), "Request failed", append(attrs, "error", log.Error, "timed_out", log.TimedOut)...)
+			} else {
+				slog.InfoContext(c.Request.Context(), "Request handled", attrs...)
+			}
+			if err := s.SaveLog(log); err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to save request log", "error", err)
+			}
+
+			return err
+		}
+	}
+}
+
+// errReader fails every read with err
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// Add this struct at the end of the file
+type bodyLogWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w bodyLogWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+)
+
+// newTestServer returns a server with the default routes served by h, and its routes
+func newTestServer(t *testing.T, h *handlers.Handler) (*Server, Routes) {
+	t.Helper()
+	srv := New(WithDefaultRoutes())
+	srv.handler = h
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		t.Fatalf("Failed to build routes: %v", err)
+	}
+	return srv, routes
+}
+
+// newTestRouter returns the public router of newTestServer running with settings
+func newTestRouter(t *testing.T, h *handlers.Handler, s store.LogSink, settings types.AppSettings) *gin.Engine {
+	t.Helper()
+	srv, routes := newTestServer(t, h)
+	srv.settings = settings
+	return srv.newPublicRouter(routes, s)
+}
+
+func TestAuthCheck(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	// Mock settings
+	var settings types.AppSettings
+	settings.AuthToken = "secret-token"
+	settings.TLSSettings.ClientSubjects = "billing-client"
+
+	// Setup temporary database
+	tmpFile := "test_router_auth.db"
+	defer os.Remove(tmpFile)
+
+	db, err := store.NewSqliteDatabase(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	s := store.NewStorage(db)
+	h := handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+
+	router := newTestRouter(t, h, s, settings)
+
+	t.Run("Missing Auth Header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("Invalid Auth Header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "wrong-token")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", w.Code)
+		}
+	})
+
+	t.Run("Valid Auth Header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "secret-token")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Valid Bearer Auth Header", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret-token")
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Verified Client Certificate", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing-client"}}}},
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+	})
+
+	t.Run("Unlisted Client Certificate", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/pong", bytes.NewBufferString(`{"test":"data"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "someone-else"}}}},
+		}
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected status 401, got %d", w.Code)
+		}
+	})
+}
+
+func TestAdminListener(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	s := store.NewStorage(db)
+	h := handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+
+	srv, routes := newTestServer(t, h)
+	srv.settings.AdminPort = "127.0.0.1:9090"
+	public := srv.newPublicRouter(routes, s)
+	admin := srv.newAdminRouter(routes, s)
+
+	get := func(router http.Handler, path string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get(public, "/healthz"); code != http.StatusNotFound {
+		t.Errorf("Expected /healthz to be missing from the public router, got %d", code)
+	}
+	if code := get(admin, "/healthz"); code != http.StatusOK {
+		t.Errorf("Expected /healthz on the admin router, got %d", code)
+	}
+	if code := get(admin, "/ping/test"); code != http.StatusNotFound {
+		t.Errorf("Expected /ping to be missing from the admin router, got %d", code)
+	}
+	if code := get(public, "/ping/test"); code != http.StatusOK {
+		t.Errorf("Expected /ping on the public router, got %d", code)
+	}
+
+	// Without an admin listener everything is public
+	srv.settings.AdminPort = ""
+	if code := get(srv.newPublicRouter(routes, s), "/healthz"); code != http.StatusOK {
+		t.Errorf("Expected /healthz on the public router without an admin listener, got %d", code)
+	}
+}
+
+func TestStaticFiles(t *testing.T) {
+	srv := New(WithAssets(fstest.MapFS{
+		"assets/css/site.css": {Data: []byte("body {}")},
+	}))
+	files := srv.staticFiles(false)
+	for _, name := range []string{"css/site.css", "js/redoc.standalone.js"} {
+		if _, err := fs.Stat(files, name); err != nil {
+			t.Errorf("Expected %s in the assets: %v", name, err)
+		}
+	}
+	if _, err := fs.Stat(files, "js/missing.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a missing file not to exist, got %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/johansundell/template-service/cache"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/health"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/scheduler"
+	"github.com/johansundell/template-service/types"
+	"github.com/johansundell/template-service/validation"
+)
+
+// operationalRoutes are served by every server: the probes, /metrics, the
+// admin endpoints and the API docs
+func (srv *Server) operationalRoutes() Routes {
+	handler := srv.handler
+	return Routes{
+		Route{
+			Name:        "Liveness",
+			Method:      "GET",
+			Pattern:     "/healthz",
+			HandlerFunc: handler.Liveness,
+			Listener:    AdminListener,
+			Summary:     "Liveness probe",
+			Response:    handlers.LivenessResponse{},
+		},
+		Route{
+			Name:        "Readiness",
+			Method:      "GET",
+			Pattern:     "/readyz",
+			HandlerFunc: handler.Readiness,
+			Listener:    AdminListener,
+			Summary:     "Readiness probe, answers the same report with 503 when a critical check fails",
+			Response:    health.Report{},
+		},
+		Route{
+			Name:        "Metrics",
+			Method:      "GET",
+			Pattern:     "/metrics",
+			HandlerFunc: srv.metricsHandler,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Prometheus metrics in the text exposition format",
+		},
+		Route{
+			Name:        "ListJobs",
+			Method:      "GET",
+			Pattern:     "/admin/jobs",
+			HandlerFunc: handler.ListJobs,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Background jobs and the outcome of their last run",
+			Response:    []scheduler.Status{},
+		},
+		Route{
+			Name:          "RunJob",
+			Method:        "POST",
+			Pattern:       "/admin/jobs/:name/run",
+			HandlerFunc:   handler.RunJob,
+			UseLogger:     true,
+			UseAuth:       true,
+			Listener:      AdminListener,
+			Summary:       "Run a background job now",
+			Response:      handlers.JobTriggeredResponse{},
+			SuccessStatus: http.StatusAccepted,
+			Errors:        []int{http.StatusNotFound, http.StatusConflict, http.StatusServiceUnavailable},
+		},
+		Route{
+			Name:        "PurgeCache",
+			Method:      "DELETE",
+			Pattern:     "/admin/cache",
+			HandlerFunc: srv.cachePurgeHandler,
+			UseLogger:   true,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Empty the response cache, or only the responses of one route",
+			Params:      []validation.Param{{Name: "route", In: validation.InQuery, Description: "Name of the route to purge"}},
+			Response:    CachePurgeResponse{},
+		},
+		Route{
+			Name:        "GetMaintenance",
+			Method:      "GET",
+			Pattern:     "/admin/maintenance",
+			HandlerFunc: handler.GetMaintenance,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Whether maintenance mode is on",
+			Response:    maintenance.State{},
+		},
+		Route{
+			Name:        "UpdateMaintenance",
+			Method:      "PUT",
+			Pattern:     "/admin/maintenance",
+			HandlerFunc: handler.UpdateMaintenance,
+			UseLogger:   true,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Switch maintenance mode on or off",
+			Request:     handlers.MaintenanceRequest{},
+			Response:    maintenance.State{},
+		},
+		Route{
+			Name:        "MaintenanceAudit",
+			Method:      "GET",
+			Pattern:     "/admin/maintenance/audit",
+			HandlerFunc: handler.MaintenanceAudit,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			Summary:     "Maintenance mode toggles, newest first",
+			Params:      []validation.Param{{Name: "limit", In: validation.InQuery, Type: validation.Integer, Rules: "gte=1,lte=1000"}},
+			Response:    []maintenance.Event{},
+		},
+		Route{
+			Name:        "APIDocs",
+			Method:      "GET",
+			Pattern:     "/docs",
+			HandlerFunc: handler.APIDocs,
+			Summary:     "API documentation page",
+		},
+	}
+}
+
+// defaultRoutes are the health page and the example routes of the template, see WithDefaultRoutes
+func defaultRoutes(srv *Server) Routes {
+	h := srv.Handler()
+	return Routes{
+		Route{
+			Name:              "HealthCheck",
+			Method:            "GET",
+			Pattern:           "/",
+			HandlerFunc:       h.HealthCheck,
+			DuringMaintenance: true,
+			Cache:             cache.Policy{TTL: 5 * time.Second, Vary: []string{"Accept"}},
+			Summary:           "Health page, as JSON when the Accept header is application/json",
+			Response:          handlers.HealthCheckResponse{},
+		},
+		Route{
+			Name:        "Ping",
+			Method:      "GET",
+			Pattern:     "/ping/:argument",
+			HandlerFunc: h.Ping,
+			UseLogger:   true,
+			Summary:     "Echo the argument",
+			Response:    handlers.PingResponse{},
+			Errors:      []int{http.StatusNotFound},
+		},
+		Route{
+			Name:        "Pong",
+			Method:      "POST",
+			Pattern:     "/pong",
+			HandlerFunc: h.Pong,
+			UseLogger:   true,
+			UseAuth:     true,
+			RateLimit:   ratelimit.Limit{Requests: 60, Per: time.Minute, By: ratelimit.ByToken},
+			Idempotent:  true,
+			Summary:     "Echo the JSON object in the body",
+			Request:     handlers.PongRequest{},
+			Response:    handlers.PongResponse{},
+		},
+		Route{
+			Name:        "GetLogs",
+			Method:      "GET",
+			Pattern:     "/logs/:from/:to",
+			HandlerFunc: h.GetLogsHandler,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			RateLimit:   ratelimit.Limit{Requests: 30, Per: time.Minute, By: ratelimit.ByToken},
+			Cache:       cache.Policy{TTL: 10 * time.Minute},
+			Summary:     "Request logs created between two dates, only ranges that have ended are cached",
+			Params: []validation.Param{
+				{Name: "from", In: validation.InPath, Type: validation.Date},
+				{Name: "to", In: validation.InPath, Type: validation.Date},
+			},
+			Response: []types.UsageLog{},
+		},
+		Route{
+			Name:        "GetLogsByRequestID",
+			Method:      "GET",
+			Pattern:     "/logs/request/:id",
+			HandlerFunc: h.GetLogsByRequestID,
+			UseAuth:     true,
+			Listener:    AdminListener,
+			RateLimit:   ratelimit.Limit{Requests: 30, Per: time.Minute, By: ratelimit.ByToken},
+			Summary:     "Request logs written for an X-Request-ID",
+			Params:      []validation.Param{{Name: "id", In: validation.InPath, Rules: "max=128"}},
+			Response:    []types.UsageLog{},
+			Errors:      []int{http.StatusNotFound},
+		},
+	}
+}
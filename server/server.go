@@ -0,0 +1,261 @@
+// Package server runs a web service built on this template: the router and
+// its middleware chain, the store, the background jobs, the listeners and
+// the command line. A service registers its routes, health checks and
+// middleware with options and passes its arguments to Run.
+package server
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cache"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/health"
+	"github.com/johansundell/template-service/idempotency"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/metrics"
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/types"
+	"github.com/kardianos/service"
+)
+
+const (
+	// defaultName names the system service, the CLI and the traces, see WithName
+	defaultName = "template-service"
+	// defaultVersion is sent in X-Version and reported by /healthz, see WithVersion
+	defaultVersion = "dev"
+)
+
+// defaultTemplates are the pages of the health, docs and maintenance routes, see WithTemplates
+//
+//go:embed tmpl/*.html
+var defaultTemplates embed.FS
+
+// defaultAssets are served on /assets behind the ones of WithAssets, like
+// the Redoc bundle of /docs. Precompressed copies are made like for the
+// service's own assets.
+//
+//go:generate go run ../tools/precompress assets
+//go:embed assets
+var defaultAssets embed.FS
+
+// Server is a web service. Options register what it serves, Run starts it
+// or one of the other commands. A process runs a single Server.
+type Server struct {
+	name      string
+	version   string
+	templates fs.FS
+	assets    fs.FS
+	routes    []routeSet
+	checks    []func(*Server) health.Check
+	// middleware wraps every route, ginMiddleware runs on every request
+	middleware    []Middleware
+	ginMiddleware []gin.HandlerFunc
+
+	// settings are read from the environment by the command that runs
+	settings types.AppSettings
+	// metrics are served on /metrics, they are created once the version is known
+	metrics *metrics.Metrics
+
+	// Set up by serve before the routes are built
+	db      *sql.DB
+	storage *store.Storage
+	handler *handlers.Handler
+	// rateLimits holds the rate limit buckets, the routers keep them in memory when it is nil
+	rateLimits ratelimit.Store
+	// idempotencyStore holds the responses replayed for an Idempotency-Key,
+	// the routers keep them in memory when it is nil
+	idempotencyStore idempotency.Store
+	// responseCache holds the responses of routes with a Cache policy, nothing is cached when it is nil
+	responseCache *cache.LRU
+	// maintenanceMode takes the routes down for maintenance, it is left out when nil
+	maintenanceMode *maintenance.Mode
+	// panicAlert is sent every recovered panic when PANIC_WEBHOOK_URL is set
+	panicAlert func(PanicReport)
+	// logger writes to the log of the service manager, set up by newService
+	logger service.Logger
+}
+
+// routeSet are routes registered by an option, with the prefix and the
+// middleware of their group
+type routeSet struct {
+	prefix     string
+	middleware []Middleware
+	routes     func(*Server) Routes
+}
+
+// Option configures a Server
+type Option func(*Server)
+
+// New creates a server. Only the operational routes, like the probes,
+// /metrics and the admin endpoints, are served unless options add more.
+func New(opts ...Option) *Server {
+	srv := &Server{
+		name:      defaultName,
+		version:   defaultVersion,
+		templates: defaultTemplates,
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	srv.metrics = metrics.New(srv.version)
+	return srv
+}
+
+// WithName names the service, the system service is installed under this name
+func WithName(name string) Option {
+	return func(srv *Server) {
+		srv.name = name
+	}
+}
+
+// WithVersion sets the version reported in X-Version, /healthz and /metrics
+func WithVersion(v string) Option {
+	return func(srv *Server) {
+		srv.version = v
+	}
+}
+
+// WithTemplates replaces the default templates with the file system holding
+// tmpl/, with base.html and the pages of the health, docs and maintenance
+// routes. USE_FILE_SYSTEM reads ./tmpl instead.
+func WithTemplates(fsys fs.FS) Option {
+	return func(srv *Server) {
+		srv.templates = fsys
+	}
+}
+
+// WithAssets serves the assets/ directory of fsys on /assets, with the .gz
+// and .br copies made by tools/precompress. USE_FILE_SYSTEM reads ./assets
+// instead. Files it doesn't have, like the Redoc bundle, come from the
+// server package.
+func WithAssets(fsys fs.FS) Option {
+	return func(srv *Server) {
+		srv.assets = fsys
+	}
+}
+
+// WithRoutes adds the routes returned by routes. It is called once the
+// store is open, so handlers can use Storage and DB.
+func WithRoutes(routes func(*Server) Routes) Option {
+	return WithGroup("", routes)
+}
+
+// WithGroup adds routes like WithRoutes, with prefix put in front of their
+// patterns and middleware around them, e.g. RequireAuth and an
+// IPFilterMiddleware for a group of admin routes. The middleware runs in the
+// order given, between the one of WithMiddleware and the routes' own, see
+// Route.Middleware.
+func WithGroup(prefix string, routes func(*Server) Routes, middleware ...Middleware) Option {
+	return func(srv *Server) {
+		srv.routes = append(srv.routes, routeSet{
+			prefix:     strings.TrimSuffix(prefix, "/"),
+			middleware: middleware,
+			routes:     routes,
+		})
+	}
+}
+
+// WithDefaultRoutes adds the health page on / and the ping, pong and log
+// routes of the template
+func WithDefaultRoutes() Option {
+	return WithRoutes(defaultRoutes)
+}
+
+// WithHealthCheck adds a check to /readyz. It is called once the store is open.
+func WithHealthCheck(check func(*Server) health.Check) Option {
+	return func(srv *Server) {
+		srv.checks = append(srv.checks, check)
+	}
+}
+
+// WithMiddleware wraps every route, including the built-in ones, in mw. It
+// runs in the order given, outside the middleware of groups, see
+// Route.Middleware.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(srv *Server) {
+		srv.middleware = append(srv.middleware, mw...)
+	}
+}
+
+// WithGinMiddleware adds gin middleware to both routers, for every request
+// including the ones without a route. It runs after the built-in middleware
+// so the client address, trace and request ID are known.
+func WithGinMiddleware(mw ...gin.HandlerFunc) Option {
+	return func(srv *Server) {
+		srv.ginMiddleware = append(srv.ginMiddleware, mw...)
+	}
+}
+
+// Settings returns the settings read from the environment
+func (srv *Server) Settings() types.AppSettings {
+	return srv.settings
+}
+
+// DB returns the database, nil until the server is set up
+func (srv *Server) DB() *sql.DB {
+	return srv.db
+}
+
+// Storage returns the store on the database, nil until the server is set up
+func (srv *Server) Storage() *store.Storage {
+	return srv.storage
+}
+
+// Handler returns the handlers of the built-in routes, nil until the server is set up
+func (srv *Server) Handler() *handlers.Handler {
+	return srv.handler
+}
+
+// Run runs the command in args, serving when there is none. It returns
+// nil when -h asked for help.
+func (srv *Server) Run(args []string) error {
+	err := srv.run(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
+
+// buildRoutes returns the operational routes and the registered ones, with
+// the OpenAPI document of all of them last. The middleware of the groups is
+// kept apart from the routes' own.
+func (srv *Server) buildRoutes() (Routes, error) {
+	routes := srv.operationalRoutes()
+	for _, set := range srv.routes {
+		for _, r := range set.routes(srv) {
+			r.Pattern = set.prefix + r.Pattern
+			r.groupMiddleware = set.middleware
+			routes = append(routes, r)
+		}
+	}
+	routes = append(routes, Route{
+		Name:        "OpenAPI",
+		Method:      "GET",
+		Pattern:     "/openapi.json",
+		HandlerFunc: srv.openAPIHandler(&routes),
+		Summary:     "This OpenAPI document",
+	})
+
+	// Names key the rate limits, caches and metrics of routes
+	names := map[string]bool{}
+	for _, r := range routes {
+		if names[r.Name] {
+			return nil, fmt.Errorf("route name %q is used twice", r.Name)
+		}
+		names[r.Name] = true
+		if r.CORS != nil {
+			if err := r.CORS.Validate(); err != nil {
+				return nil, fmt.Errorf("route %s: CORS: %w", r.Name, err)
+			}
+		}
+	}
+	return routes, nil
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/cache"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/store"
+)
+
+func TestBuildRoutes(t *testing.T) {
+	find := func(routes Routes, name string) *Route {
+		for i := range routes {
+			if routes[i].Name == name {
+				return &routes[i]
+			}
+		}
+		return nil
+	}
+	hello := func(*Server) Routes {
+		return Routes{{Name: "Hello", Method: "GET", Pattern: "/hello"}}
+	}
+
+	t.Run("Operational routes only", func(t *testing.T) {
+		routes, err := New().buildRoutes()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if find(routes, "Readiness") == nil || find(routes, "OpenAPI") == nil {
+			t.Errorf("Expected the probes and the OpenAPI document")
+		}
+		if find(routes, "Ping") != nil || find(routes, "HealthCheck") != nil {
+			t.Errorf("Expected the default routes to be left out")
+		}
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		routes, err := New(WithRoutes(hello), WithGroup("/v1/", func(*Server) Routes {
+			return Routes{{Name: "HelloV1", Method: "GET", Pattern: "/hello"}}
+		})).buildRoutes()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if r := find(routes, "Hello"); r == nil || r.Pattern != "/hello" {
+			t.Errorf("Expected /hello, got %+v", r)
+		}
+		if r := find(routes, "HelloV1"); r == nil || r.Pattern != "/v1/hello" {
+			t.Errorf("Expected /v1/hello, got %+v", r)
+		}
+	})
+
+	t.Run("Duplicate names", func(t *testing.T) {
+		if _, err := New(WithRoutes(hello), WithRoutes(hello)).buildRoutes(); err == nil {
+			t.Errorf("Expected an error for a name used twice")
+		}
+	})
+}
+
+func TestWithGinMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	s := store.NewStorage(db)
+	srv := New(WithGinMiddleware(func(c *gin.Context) {
+		c.Header("X-Tenant", "acme")
+		c.Next()
+	}))
+	srv.handler = handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		t.Fatalf("Failed to build routes: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	srv.newPublicRouter(routes, s).ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("X-Tenant") != "acme" {
+		t.Errorf("Expected 200 with X-Tenant from the middleware, got %d %q", w.Code, w.Header().Get("X-Tenant"))
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := store.NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	var calls []string
+	record := func(name string) Middleware {
+		return func(inner HandlerFuncWithError) HandlerFuncWithError {
+			return func(c *gin.Context) error {
+				calls = append(calls, name)
+				return inner(c)
+			}
+		}
+	}
+
+	s := store.NewStorage(db)
+	srv := New(
+		WithMiddleware(record("global")),
+		WithGroup("/admin", func(*Server) Routes {
+			return Routes{{
+				Name:       "Report",
+				Method:     "GET",
+				Pattern:    "/report",
+				Middleware: []Middleware{record("route")},
+				HandlerFunc: func(c *gin.Context) error {
+					calls = append(calls, "handler")
+					c.String(http.StatusOK, "ok")
+					return nil
+				},
+			}}
+		}, RequireAuth, record("group")),
+	)
+	srv.handler = handlers.NewHandler(s, false, fstest.MapFS{}, "test", "dev")
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		t.Fatalf("Failed to build routes: %v", err)
+	}
+	srv.settings.AuthToken = "secret-token"
+	router := srv.newPublicRouter(routes, s)
+
+	get := func(token string) int {
+		calls = nil
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/report", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := get("secret-token"); code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", code)
+	}
+	if got := strings.Join(calls, ","); got != "global,group,route,handler" {
+		t.Errorf("Expected global,group,route,handler, got %s", got)
+	}
+
+	// RequireAuth runs at its place, the group middleware after it only sees verified clients
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401, got %d", code)
+	}
+	if got := strings.Join(calls, ","); got != "global" {
+		t.Errorf("Expected only the global middleware to run, got %s", got)
+	}
+
+	report := routes[slices.IndexFunc(routes, func(r Route) bool { return r.Name == "Report" })]
+	if !srv.requiresAuth(report) {
+		t.Errorf("Expected RequireAuth to require auth")
+	}
+	report.RateLimit = ratelimit.Limit{Requests: 1, Per: time.Minute, By: ratelimit.ByToken}
+	report.Cache = cache.Policy{TTL: time.Minute}
+	srv.responseCache = cache.New(1 << 20)
+	srv.settings.Timeout, srv.settings.MaxBodyBytes = 15, 1<<20
+	rc := routerConfig{settings: srv.settings, limits: ratelimit.NewMemoryStore()}
+	want := "metrics,bodyLimit,recovery,global,rateLimit,auth,group,route,tokenRateLimit,cache,timeout,validation"
+	if got := strings.Join(srv.middlewareChain(report, rc).names(), ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	// UseAuth runs auth in front of the route's own list, once
+	report.UseAuth = true
+	report.groupMiddleware = []Middleware{record("group")}
+	report.RateLimit = ratelimit.Limit{Requests: 1, Per: time.Minute, By: ratelimit.ByIP}
+	want = "metrics,bodyLimit,recovery,global,group,rateLimit,auth,route,cache,timeout,validation"
+	if got := strings.Join(srv.middlewareChain(report, rc).names(), ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(inner HandlerFuncWithError) HandlerFuncWithError {
+			return func(c *gin.Context) error {
+				calls = append(calls, name)
+				return inner(c)
+			}
+		}
+	}
+	h := Chain(record("a"), record("b"))(func(*gin.Context) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+	if err := h(nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := strings.Join(calls, ","); got != "a,b,handler" {
+		t.Errorf("Expected a,b,handler, got %s", got)
+	}
+	if Chain()(nil) != nil {
+		t.Errorf("Expected an empty chain to return the handler")
+	}
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/johansundell/template-service/cache"
+	"github.com/johansundell/template-service/handlers"
+	"github.com/johansundell/template-service/listener"
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/scheduler"
+	"github.com/johansundell/template-service/store"
+	"github.com/johansundell/template-service/tlsutil"
+	"github.com/johansundell/template-service/tracing"
+	"github.com/kardianos/service"
+	"github.com/quic-go/quic-go/http3"
+)
+
+const sqliteFile = "test.db"
+
+// Slow clients may hold a connection this long while sending the headers or
+// between requests. Reading the body and writing the response are bounded
+// by the route timeouts instead, so downloads and streams are not cut off.
+const (
+	readHeaderTimeout = 10 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+type program struct {
+	server      *Server
+	db          *sql.DB
+	logWriter   *store.LogWriter
+	srv         *http.Server
+	listener    net.Listener
+	redirectSrv *http.Server
+	redirectLn  net.Listener
+	h3Srv       *http3.Server
+	h3Conn      net.PacketConn
+	adminSrv    *http.Server
+	adminLn     net.Listener
+	scheduler   *scheduler.Scheduler
+	// stopTracing flushes the spans that are still buffered
+	stopTracing func(context.Context) error
+	// stopMaintenance stops watching the maintenance flag file
+	stopMaintenance context.CancelFunc
+
+	stopOnce sync.Once
+	stopErr  error
+}
+
+func (p *program) Start(s service.Service) error {
+	if service.Interactive() {
+		p.server.logger.Info("Running in terminal.")
+	} else {
+		p.server.logger.Info("Running under service manager.")
+	}
+	p.server.logger.Infof("I'm running %v, with version %v.", service.Platform(), p.server.version)
+
+	// Everything up to binding the listener runs synchronously, so that a
+	// broken database or a taken port is reported back to the service manager.
+	if err := p.setup(); err != nil {
+		// Nothing is serving yet, so the listeners have to be closed by hand
+		for _, ln := range []net.Listener{p.listener, p.redirectLn, p.adminLn} {
+			if ln != nil {
+				ln.Close()
+			}
+		}
+		if p.h3Conn != nil {
+			p.h3Conn.Close()
+		}
+		p.shutdown()
+		return err
+	}
+
+	// Start should not block. Serve requests async.
+	go p.serve("HTTP server", func() error {
+		if p.srv.TLSConfig != nil {
+			return p.srv.ServeTLS(p.listener, "", "")
+		}
+		return p.srv.Serve(p.listener)
+	})
+	if p.redirectSrv != nil {
+		go p.serve("HTTP redirect server", func() error {
+			return p.redirectSrv.Serve(p.redirectLn)
+		})
+	}
+	if p.h3Srv != nil {
+		go p.serve("HTTP/3 server", func() error {
+			return p.h3Srv.Serve(p.h3Conn)
+		})
+	}
+	if p.adminSrv != nil {
+		go p.serve("Admin server", func() error {
+			if p.adminSrv.TLSConfig != nil {
+				return p.adminSrv.ServeTLS(p.adminLn, "", "")
+			}
+			return p.adminSrv.Serve(p.adminLn)
+		})
+	}
+	p.scheduler.Start()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.stopMaintenance = cancel
+	go p.server.maintenanceMode.Watch(ctx, maintenanceWatchInterval)
+	return nil
+}
+
+func (p *program) setup() error {
+	srv, settings := p.server, p.server.settings
+	slog.SetDefault(newLogger(settings.Debug))
+
+	stopTracing, err := tracing.Setup(context.Background(), srv.tracingConfig())
+	if err != nil {
+		return fmt.Errorf("set up tracing: %w", err)
+	}
+	p.stopTracing = stopTracing
+
+	db, err := srv.openDatabase(true)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+	p.db = db
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+
+	if !settings.Debug && settings.AuthToken == "" {
+		log.Println("WARNING: AUTH_TOKEN is not set in non-debug mode. Security is disabled.")
+	}
+
+	storage := store.NewStorage(db)
+	p.logWriter = store.NewLogWriter(storage, settings.LogQueueSize)
+	if err := srv.metrics.RegisterDB(db, "main"); err != nil {
+		return fmt.Errorf("register metrics: %w", err)
+	}
+	if err := srv.metrics.RegisterLogQueue(p.logWriter.Len, p.logWriter.Cap(), p.logWriter.Dropped); err != nil {
+		return fmt.Errorf("register metrics: %w", err)
+	}
+	handler := handlers.NewHandler(storage, settings.UseFileSystem, srv.templates, srv.name, srv.version)
+	srv.db, srv.storage, srv.handler = db, storage, handler
+	checks := srv.newHealthRegistry(storage, p.logWriter)
+	for _, check := range srv.checks {
+		checks.Register(check(srv))
+	}
+	handler.SetHealthRegistry(checks)
+	if _, err := parseRateLimits(settings.RateLimits); err != nil {
+		return fmt.Errorf("RATE_LIMITS: %w", err)
+	}
+	if settings.RateLimitBackend == "store" {
+		srv.rateLimits = store.NewRateLimitStore(storage)
+	}
+	srv.idempotencyStore = store.NewIdempotencyStore(storage)
+	if settings.CacheMaxBytes > 0 {
+		srv.responseCache = cache.New(settings.CacheMaxBytes)
+	}
+
+	maintenanceMode := maintenance.New(settings.MaintenanceFile, settings.MaintenanceRetryAfter, storage)
+	last, err := storage.LastMaintenanceEvent(context.Background())
+	if err != nil {
+		return fmt.Errorf("restore maintenance mode: %w", err)
+	}
+	maintenanceMode.Restore(last)
+	if err := maintenanceMode.CheckFile(context.Background()); err != nil {
+		return fmt.Errorf("check MAINTENANCE_FILE: %w", err)
+	}
+	if maintenanceMode.Enabled() {
+		srv.logger.Warning("Starting in maintenance mode.")
+	}
+	handler.SetMaintenance(maintenanceMode)
+	srv.maintenanceMode = maintenanceMode
+
+	if settings.PanicWebhookURL != "" {
+		srv.panicAlert = newPanicWebhook(settings.PanicWebhookURL)
+	}
+
+	p.scheduler, err = srv.newScheduler(storage)
+	if err != nil {
+		return fmt.Errorf("schedule jobs: %w", err)
+	}
+	handler.SetScheduler(p.scheduler)
+
+	routes, err := srv.buildRoutes()
+	if err != nil {
+		return err
+	}
+	router := srv.newPublicRouter(routes, p.logWriter)
+	p.srv = &http.Server{
+		Handler:           router,
+		Addr:              settings.Port,
+		ReadHeaderTimeout: readHeaderTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	if tlsConfig(settings).Enabled() {
+		p.srv.TLSConfig, err = tlsutil.NewServerConfig(tlsConfig(settings))
+		if err != nil {
+			return fmt.Errorf("configure TLS: %w", err)
+		}
+	}
+
+	sockets, err := listener.Activated()
+	if err != nil {
+		return fmt.Errorf("socket activation: %w", err)
+	}
+	p.listener, err = srv.listen(sockets, publicSocketName, settings.Port)
+	if err != nil {
+		return err
+	}
+
+	if settings.HTTP3Enabled {
+		if p.srv.TLSConfig == nil {
+			return errors.New("HTTP/3 needs TLS, set TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		p.h3Srv = newHTTP3Server(p.srv.Handler, p.srv.TLSConfig)
+		p.h3Conn, err = listenHTTP3(http3Address(settings))
+		if err != nil {
+			return fmt.Errorf("listen on udp %s: %w", http3Address(settings), err)
+		}
+		p.srv.Handler = altSvcHandler(p.h3Srv, p.srv.Handler)
+	}
+
+	if settings.AdminPort != "" {
+		p.adminSrv = &http.Server{
+			Handler:           srv.newAdminRouter(routes, p.logWriter),
+			Addr:              settings.AdminPort,
+			ReadHeaderTimeout: readHeaderTimeout,
+			IdleTimeout:       idleTimeout,
+		}
+		if settings.AdminTLS {
+			if p.srv.TLSConfig == nil {
+				return errors.New("ADMIN_TLS needs TLS, set TLS_CERT_FILE and TLS_KEY_FILE")
+			}
+			p.adminSrv.TLSConfig = p.srv.TLSConfig
+		}
+		p.adminLn, err = srv.listen(sockets, adminSocketName, settings.AdminPort)
+		if err != nil {
+			return err
+		}
+	}
+
+	if p.srv.TLSConfig != nil && settings.TLSSettings.RedirectPort != "" {
+		p.redirectSrv = &http.Server{
+			Handler:           newRedirectHandler(settings.Port),
+			Addr:              settings.TLSSettings.RedirectPort,
+			ReadHeaderTimeout: readHeaderTimeout,
+			IdleTimeout:       idleTimeout,
+		}
+		p.redirectLn, err = net.Listen("tcp", settings.TLSSettings.RedirectPort)
+		if err != nil {
+			return fmt.Errorf("listen on %s: %w", settings.TLSSettings.RedirectPort, err)
+		}
+	}
+	return nil
+}
+
+// serve runs a server until it is shut down. If the server fails on its own
+// the program is stopped and the process exits with an error, so the
+// service manager can restart it.
+func (p *program) serve(name string, run func() error) {
+	err := run()
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		return
+	}
+	p.server.logger.Errorf("%s failed: %v", name, err)
+	if err := p.shutdown(); err != nil {
+		p.server.logger.Error(err)
+	}
+	os.Exit(1)
+}
+
+// openDatabase opens the configured database, migrating it to the latest schema when migrate is set
+func (srv *Server) openDatabase(migrate bool) (*sql.DB, error) {
+	settings := srv.settings
+	if settings.UseMySQL {
+		cfg := mysql.Config{
+			User:                 settings.MySqlSettings.Username,
+			Passwd:               settings.MySqlSettings.Password,
+			Net:                  "tcp",
+			Addr:                 settings.MySqlSettings.Host + ":3306",
+			DBName:               settings.MySqlSettings.Database,
+			AllowNativePasswords: true,
+			ParseTime:            true,
+		}
+		if !migrate {
+			return store.OpenMySQLStorage(cfg)
+		}
+		return store.NewMySQLStorage(cfg)
+	}
+	if !migrate {
+		return store.OpenSqliteDatabase(sqliteFile)
+	}
+	return store.NewSqliteDatabase(sqliteFile)
+}
+
+// stoppableServer is implemented by both the HTTP/1.1+2 and the HTTP/3 server
+type stoppableServer interface {
+	Shutdown(ctx context.Context) error
+	Close() error
+}
+
+// shutdown stops the subsystems in order: the servers first so in-flight
+// requests can finish, then the background jobs, then the log writer so
+// their logs are flushed, and the database last. Each phase gets the configured shutdown timeout.
+func (p *program) shutdown() error {
+	p.stopOnce.Do(func() {
+		timeout := time.Duration(p.server.settings.ShutdownTimeout) * time.Second
+		var errs []error
+
+		servers := map[string]stoppableServer{}
+		if p.redirectSrv != nil {
+			servers["http redirect server"] = p.redirectSrv
+		}
+		if p.h3Srv != nil {
+			servers["http/3 server"] = p.h3Srv
+		}
+		if p.srv != nil {
+			servers["http server"] = p.srv
+		}
+		if p.adminSrv != nil {
+			servers["admin server"] = p.adminSrv
+		}
+		errs = append(errs, shutdownServers(servers, timeout)...)
+		if p.h3Conn != nil {
+			// The HTTP/3 server doesn't own its UDP socket
+			p.h3Conn.Close()
+		}
+
+		if p.stopMaintenance != nil {
+			p.stopMaintenance()
+		}
+		if p.scheduler != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := p.scheduler.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("stop scheduler: %w", err))
+			}
+			cancel()
+		}
+		if p.logWriter != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := p.logWriter.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("drain request logs: %w", err))
+			}
+			cancel()
+		}
+		if p.stopTracing != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			if err := p.stopTracing(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("flush traces: %w", err))
+			}
+			cancel()
+		}
+		if p.db != nil {
+			if err := p.db.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("close database: %w", err))
+			}
+		}
+		p.stopErr = errors.Join(errs...)
+	})
+	return p.stopErr
+}
+
+// shutdownServers drains all servers at once, and closes the ones that
+// still have connections when the timeout expires.
+func shutdownServers(servers map[string]stoppableServer, timeout time.Duration) []error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for name, srv := range servers {
+		wg.Add(1)
+		go func(name string, srv stoppableServer) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				srv.Close()
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("shutdown %s: %w", name, err))
+				mu.Unlock()
+			}
+		}(name, srv)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (p *program) Stop(s service.Service) error {
+	// Any work in Stop should be quick, usually a few seconds at most.
+	p.server.logger.Info("I'm Stopping!")
+	return p.shutdown()
+}
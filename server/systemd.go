@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/johansundell/template-service/listener"
+	"github.com/kardianos/service"
+)
+
+const systemdUnitDir = "/etc/systemd/system"
+
+const socketUnitTemplate = `[Unit]
+Description={{.Description}} socket
+
+[Socket]
+ListenStream={{.Listen}}
+FileDescriptorName={{.FDName}}
+{{- if .Unix}}
+SocketMode={{.Mode}}
+{{- if .Group}}
+SocketGroup={{.Group}}
+{{- end}}
+{{- end}}
+
+[Install]
+WantedBy=sockets.target
+`
+
+// socketUnit is a systemd socket unit handing one listener to the service
+type socketUnit struct {
+	name   string
+	listen string
+	fdName string
+}
+
+// socketUnits are the units for SYSTEMD_SOCKET and ADMIN_SYSTEMD_SOCKET. A
+// unit names all its sockets alike, so each listener needs its own.
+func (srv *Server) socketUnits() []socketUnit {
+	var units []socketUnit
+	if srv.settings.SystemdSocket != "" {
+		units = append(units, socketUnit{srv.name + ".socket", srv.settings.SystemdSocket, publicSocketName})
+	}
+	if srv.settings.AdminSystemdSocket != "" {
+		units = append(units, socketUnit{srv.name + "-admin.socket", srv.settings.AdminSystemdSocket, adminSocketName})
+	}
+	return units
+}
+
+// usesSocketActivation reports whether install should add systemd socket units
+func (srv *Server) usesSocketActivation() bool {
+	return len(srv.socketUnits()) > 0 && service.ChosenSystem() != nil && service.ChosenSystem().String() == "linux-systemd"
+}
+
+// socketActivationDependencies makes the service unit start after, and together with, its socket units
+func (srv *Server) socketActivationDependencies() []string {
+	var deps []string
+	for _, u := range srv.socketUnits() {
+		deps = append(deps, "Requires="+u.name, "After="+u.name)
+	}
+	return deps
+}
+
+// socketUnitFile renders the unit file of u
+func (srv *Server) socketUnitFile(u socketUnit) ([]byte, error) {
+	var buf bytes.Buffer
+	tmpl := template.Must(template.New("socket").Parse(socketUnitTemplate))
+	err := tmpl.Execute(&buf, map[string]interface{}{
+		"Description": srv.name,
+		"Listen":      strings.TrimPrefix(u.listen, listener.UnixPrefix),
+		"FDName":      u.fdName,
+		"Unix":        listener.IsUnix(u.listen),
+		"Mode":        srv.settings.UnixSocketMode,
+		"Group":       srv.settings.UnixSocketGroup,
+	})
+	return buf.Bytes(), err
+}
+
+// installSocketUnits writes and enables the socket units. systemd holds the
+// sockets open, so connections queue up instead of being refused while the
+// service restarts.
+func (srv *Server) installSocketUnits() error {
+	units := srv.socketUnits()
+	for _, u := range units {
+		unit, err := srv.socketUnitFile(u)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(systemdUnitDir, u.name)
+		if err := os.WriteFile(path, unit, 0644); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	for _, u := range units {
+		if err := systemctl("enable", u.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSocketUnits disables and deletes the socket units written by
+// installSocketUnits, also the ones no longer configured
+func (srv *Server) removeSocketUnits() error {
+	removed := false
+	for _, name := range []string{srv.name + ".socket", srv.name + "-admin.socket"} {
+		path := filepath.Join(systemdUnitDir, name)
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := systemctl("disable", "--now", name); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed = true
+	}
+	if !removed {
+		return nil
+	}
+	return systemctl("daemon-reload")
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
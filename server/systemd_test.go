@@ -0,0 +1,44 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSocketUnits(t *testing.T) {
+	srv := New()
+	srv.settings.SystemdSocket = "unix:/run/app.sock"
+	srv.settings.AdminSystemdSocket = "127.0.0.1:9090"
+	srv.settings.UnixSocketMode = "0660"
+
+	units := srv.socketUnits()
+	if len(units) != 2 {
+		t.Fatalf("Expected a public and an admin unit, got %d", len(units))
+	}
+
+	public, err := srv.socketUnitFile(units[0])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, line := range []string{"ListenStream=/run/app.sock", "FileDescriptorName=http", "SocketMode=0660"} {
+		if !strings.Contains(string(public), line) {
+			t.Errorf("Expected %q in the public unit:\n%s", line, public)
+		}
+	}
+
+	admin, err := srv.socketUnitFile(units[1])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if units[1].name != defaultName+"-admin.socket" || !strings.Contains(string(admin), "FileDescriptorName=admin") {
+		t.Errorf("Expected the admin unit to name its socket admin, got %s:\n%s", units[1].name, admin)
+	}
+	if strings.Contains(string(admin), "SocketMode") {
+		t.Errorf("Expected no SocketMode for a TCP socket:\n%s", admin)
+	}
+
+	deps := strings.Join(srv.socketActivationDependencies(), " ")
+	if !strings.Contains(deps, "After="+defaultName+"-admin.socket") {
+		t.Errorf("Expected the service to start after the admin socket, got %s", deps)
+	}
+}
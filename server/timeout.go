@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+)
+
+// NoTimeout disables the request timeout for a route, for streaming responses like SSE
+const NoTimeout time.Duration = -1
+
+// errRequestTimeout marks a request that ran past its route's timeout
+var errRequestTimeout = errors.New("request timed out")
+
+// routeTimeout returns the timeout a route runs with, the configured default when the route sets none
+func routeTimeout(r Route, def time.Duration) time.Duration {
+	if r.Timeout < 0 {
+		return 0
+	}
+	if r.Timeout == 0 {
+		return def
+	}
+	return r.Timeout
+}
+
+// TimeoutMiddleware puts a deadline on the request context. Handlers are
+// expected to pass the context on to anything that blocks, when the deadline
+// passes before a response is written the request is answered with a 504.
+func TimeoutMiddleware(timeout time.Duration) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+			defer cancel()
+			c.Request = c.Request.WithContext(ctx)
+			c.Set("requestTimeout", timeout)
+
+			err := inner(c)
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) || c.Writer.Written() {
+				return err
+			}
+			return httperror.ReturnWithHTTPStatus(
+				fmt.Errorf("%w after %s", errRequestTimeout, timeout),
+				http.StatusGatewayTimeout,
+			)
+		}
+	}
+}
+
+// timedOut reports whether err was caused by a route timeout
+func timedOut(err error) bool {
+	return errors.Is(err, errRequestTimeout)
+}
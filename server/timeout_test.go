@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/types"
+)
+
+type logSinkFunc func(types.UsageLog) error
+
+func (f logSinkFunc) SaveLog(l types.UsageLog) error { return f(l) }
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+
+	slow := func(c *gin.Context) error {
+		select {
+		case <-c.Request.Context().Done():
+			return c.Request.Context().Err()
+		case <-time.After(time.Second):
+			c.String(http.StatusOK, "done")
+			return nil
+		}
+	}
+	fast := func(c *gin.Context) error {
+		c.String(http.StatusOK, "done")
+		return nil
+	}
+
+	router := gin.New()
+	router.GET("/slow", WrapHandler(LoggerMiddleware(sink)(TimeoutMiddleware(20*time.Millisecond)(slow))))
+	router.GET("/fast", WrapHandler(LoggerMiddleware(sink)(TimeoutMiddleware(time.Second)(fast))))
+
+	t.Run("Timed out", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+
+		if w.Code != http.StatusGatewayTimeout {
+			t.Fatalf("Expected status 504, got %d", w.Code)
+		}
+		var body map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expected a JSON body, got %q", w.Body.String())
+		}
+		if !saved.TimedOut || saved.TimeoutMs != 20 || saved.Status != http.StatusGatewayTimeout {
+			t.Errorf("Expected the timeout in the request log, got %+v", saved)
+		}
+	})
+
+	t.Run("In time", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if saved.TimedOut || saved.TimeoutMs != 1000 {
+			t.Errorf("Expected the route timeout without a timeout flag, got %+v", saved)
+		}
+	})
+}
+
+func TestRouteTimeout(t *testing.T) {
+	def := 15 * time.Second
+	if got := routeTimeout(Route{}, def); got != def {
+		t.Errorf("Expected the default timeout, got %s", got)
+	}
+	if got := routeTimeout(Route{Timeout: time.Minute}, def); got != time.Minute {
+		t.Errorf("Expected the route timeout, got %s", got)
+	}
+	if got := routeTimeout(Route{Timeout: NoTimeout}, def); got != 0 {
+		t.Errorf("Expected no timeout for a streaming route, got %s", got)
+	}
+}
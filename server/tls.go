@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/tlsutil"
+	"github.com/johansundell/template-service/types"
+)
+
+func tlsConfig(s types.AppSettings) tlsutil.Config {
+	return tlsutil.Config{
+		CertFile:     s.TLSSettings.CertFile,
+		KeyFile:      s.TLSSettings.KeyFile,
+		MinVersion:   s.TLSSettings.MinVersion,
+		CipherPolicy: s.TLSSettings.CipherPolicy,
+		ClientCAFile: s.TLSSettings.ClientCAFile,
+		ClientAuth:   s.TLSSettings.ClientAuth,
+	}
+}
+
+// clientIdentity returns the identity of the verified client certificate, if any
+func clientIdentity(c *gin.Context) string {
+	return tlsutil.ClientIdentity(c.Request.TLS)
+}
+
+// clientSubjects are the certificate subjects accepted in place of AUTH_TOKEN, see TLS_CLIENT_SUBJECTS
+func clientSubjects(s types.AppSettings) []string {
+	return splitList(s.TLSSettings.ClientSubjects)
+}
+
+// subjectAllowed reports whether a verified certificate subject may stand in for the token
+func subjectAllowed(identity string, subjects []string) bool {
+	return slices.Contains(subjects, "*") || slices.Contains(subjects, identity)
+}
+
+// newRedirectHandler sends plain HTTP requests to the same path over HTTPS on httpsAddr
+func newRedirectHandler(httpsAddr string) http.Handler {
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingConfig builds the exporter config from the settings
+func (srv *Server) tracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:       srv.settings.TracingSettings.Exporter,
+		Endpoint:       srv.settings.TracingSettings.Endpoint,
+		SampleRatio:    srv.settings.TracingSettings.SampleRatio,
+		ServiceName:    srv.name,
+		ServiceVersion: srv.version,
+	}
+}
+
+// TracingMiddleware continues the caller's W3C trace, or starts a new one, with
+// a server span per request. routeNames maps "METHOD pattern" to the route name.
+func TracingMiddleware(routeNames map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pattern := c.FullPath()
+		if pattern == "" {
+			// Unmatched paths would give every 404 its own span name
+			pattern = "unmatched"
+		}
+		ctx := tracing.Extract(c.Request.Context(), c.Request.Header)
+		ctx, span := tracing.Tracer().Start(ctx, c.Request.Method+" "+pattern,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.HTTPRoute(pattern),
+				semconv.URLPath(c.Request.URL.Path),
+			),
+		)
+		defer span.End()
+		if name, ok := routeNames[c.Request.Method+" "+pattern]; ok {
+			span.SetAttributes(attribute.String("route.name", name))
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		tracing.SetStatus(span, c.Writer.Status(), trace.SpanKindServer)
+	}
+}
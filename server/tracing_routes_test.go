@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/requestid"
+	"github.com/johansundell/template-service/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rec := tracetest.NewSpanRecorder()
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+
+	router := gin.New()
+	router.Use(TracingMiddleware(map[string]string{"GET /items/:id": "GetItem"}))
+	router.Use(RequestIDMiddleware())
+	router.GET("/items/:id", WrapHandler(LoggerMiddleware(sink)(func(c *gin.Context) error {
+		c.String(http.StatusOK, "ok")
+		return nil
+	})))
+
+	t.Run("new trace", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/items/1", nil)
+		router.ServeHTTP(w, req)
+
+		spans := rec.Ended()
+		span := spans[len(spans)-1]
+		if span.Name() != "GET /items/:id" {
+			t.Errorf("Expected the span to be named by the route pattern, got %q", span.Name())
+		}
+		attrs := map[string]string{}
+		for _, kv := range span.Attributes() {
+			attrs[string(kv.Key)] = kv.Value.Emit()
+		}
+		if attrs["route.name"] != "GetItem" || attrs["http.route"] != "/items/:id" || attrs["http.response.status_code"] != "200" {
+			t.Errorf("Unexpected span attributes %v", attrs)
+		}
+
+		traceID := span.SpanContext().TraceID().String()
+		if saved.TraceID != traceID {
+			t.Errorf("Expected trace ID %q in the request log, got %q", traceID, saved.TraceID)
+		}
+		if got := w.Header().Get(requestid.Header); got != traceID {
+			t.Errorf("Expected the generated request ID to be the trace ID, got %q", got)
+		}
+	})
+
+	t.Run("continues the caller's trace", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/items/2", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		req.Header.Set(requestid.Header, "client-id")
+		router.ServeHTTP(w, req)
+
+		spans := rec.Ended()
+		span := spans[len(spans)-1]
+		if span.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("Expected the caller's trace ID, got %s", span.SpanContext().TraceID())
+		}
+		if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+			t.Errorf("Expected the caller's span as parent, got %s", span.Parent().SpanID())
+		}
+		if saved.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || saved.RequestID != "client-id" {
+			t.Errorf("Unexpected log trace ID %q and request ID %q", saved.TraceID, saved.RequestID)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/httperror"
+	"github.com/johansundell/template-service/validation"
+)
+
+// NoBodyLimit lifts the request body limit for a route, e.g. for uploads
+const NoBodyLimit int64 = -1
+
+// routeBodyLimit returns the body limit a route runs with, the configured default when the route sets none
+func routeBodyLimit(r Route, def int64) int64 {
+	if r.MaxBodyBytes < 0 {
+		return 0
+	}
+	if r.MaxBodyBytes == 0 {
+		return def
+	}
+	return r.MaxBodyBytes
+}
+
+// BodyLimitMiddleware caps the request body at n bytes. It only wraps the
+// body, reading past the limit fails with an *http.MaxBytesError.
+func BodyLimitMiddleware(n int64) Middleware {
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			if c.Request.Body != nil {
+				c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, n)
+			}
+			return inner(c)
+		}
+	}
+}
+
+// ValidationMiddleware checks the route's parameters and request body before
+// the handler runs. All failing fields are answered at once with a 400. The
+// body is only read when the route has a Request type, otherwise the handler
+// gets the stream untouched, e.g. for uploads.
+func ValidationMiddleware(r Route) Middleware {
+	var bodyType reflect.Type
+	if r.Request != nil {
+		bodyType = reflect.TypeOf(r.Request)
+	}
+	return func(inner HandlerFuncWithError) HandlerFuncWithError {
+		return func(c *gin.Context) error {
+			var fields []validation.FieldError
+			query := c.Request.URL.Query()
+			for _, p := range r.Params {
+				var value string
+				var present bool
+				if p.In == validation.InQuery {
+					value, present = query.Get(p.Name), query.Has(p.Name)
+				} else {
+					value, present = c.Params.Get(p.Name)
+				}
+				if fe := validation.CheckParam(p, value, present); fe != nil {
+					fields = append(fields, *fe)
+				}
+			}
+
+			switch {
+			case bodyType == nil:
+				// The handler reads the body itself, e.g. a streamed upload
+			case c.Request.Body != nil && c.Request.Body != http.NoBody:
+				data, err := io.ReadAll(c.Request.Body)
+				c.Request.Body.Close()
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					return httperror.ReturnWithHTTPStatus(
+						fmt.Errorf("request body is larger than %d bytes", tooLarge.Limit),
+						http.StatusRequestEntityTooLarge,
+					)
+				}
+				if err != nil {
+					return httperror.ReturnWithHTTPStatus(err, http.StatusBadRequest)
+				}
+				c.Request.Body = io.NopCloser(bytes.NewReader(data))
+				fields = append(fields, validation.DecodeBody(data, reflect.New(bodyType).Interface())...)
+			default:
+				fields = append(fields, validation.FieldError{In: validation.InBody, Message: "is required"})
+			}
+
+			if len(fields) > 0 {
+				return httperror.ReturnWithHTTPStatus(&validation.Error{Fields: fields}, http.StatusBadRequest)
+			}
+			return inner(c)
+		}
+	}
+}
+
+// validationFailed returns the field errors behind err, if any
+func validationFailed(err error) (*validation.Error, bool) {
+	var verr *validation.Error
+	ok := errors.As(err, &verr)
+	return verr, ok
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/johansundell/template-service/types"
+	"github.com/johansundell/template-service/validation"
+)
+
+func TestValidationMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type body struct {
+		Name string `json:"name" binding:"required,max=5"`
+	}
+	var saved types.UsageLog
+	sink := logSinkFunc(func(l types.UsageLog) error {
+		saved = l
+		return nil
+	})
+	route := Route{
+		Request: body{},
+		Params: []validation.Param{
+			{Name: "id", In: validation.InPath, Type: validation.Integer},
+			{Name: "limit", In: validation.InQuery, Type: validation.Integer, Rules: "min=1,max=100"},
+		},
+	}
+	ok := func(c *gin.Context) error {
+		var b body
+		if err := c.ShouldBindJSON(&b); err != nil {
+			return err
+		}
+		c.String(http.StatusOK, b.Name)
+		return nil
+	}
+
+	router := gin.New()
+	router.POST("/items/:id", WrapHandler(BodyLimitMiddleware(32)(LoggerMiddleware(sink)(ValidationMiddleware(route)(ok)))))
+
+	post := func(path, payload string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/items/1?limit=10", `{"name":"abc"}`); w.Code != http.StatusOK || w.Body.String() != "abc" {
+		t.Errorf("Expected the handler to get the body, got %d %q", w.Code, w.Body.String())
+	}
+
+	w := post("/items/x?limit=500", `{"name":"abcdef"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	var res errorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Expected a JSON body, got %q", w.Body.String())
+	}
+	want := []validation.FieldError{
+		{Field: "id", In: "path", Message: "must be an integer"},
+		{Field: "limit", In: "query", Message: "must be at most 100"},
+		{Field: "name", In: "body", Message: "must be at most 5 characters"},
+	}
+	if len(res.Fields) != len(want) {
+		t.Fatalf("Expected fields %+v, got %+v", want, res.Fields)
+	}
+	for i := range want {
+		if res.Fields[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], res.Fields[i])
+		}
+	}
+	if saved.Status != http.StatusBadRequest {
+		t.Errorf("Expected the failed validation to be logged, got %d", saved.Status)
+	}
+
+	w = post("/items/1", `{"name":"`+strings.Repeat("a", 64)+`"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status 413, got %d", w.Code)
+	}
+	if len(saved.Request) > 32 {
+		t.Errorf("Expected the logger to read no more than the limit, got %d bytes", len(saved.Request))
+	}
+}
+
+func TestRouteBodyLimit(t *testing.T) {
+	if got := routeBodyLimit(Route{}, 1024); got != 1024 {
+		t.Errorf("Expected the default limit, got %d", got)
+	}
+	if got := routeBodyLimit(Route{MaxBodyBytes: 10}, 1024); got != 10 {
+		t.Errorf("Expected the route limit, got %d", got)
+	}
+	if got := routeBodyLimit(Route{MaxBodyBytes: NoBodyLimit}, 1024); got != 0 {
+		t.Errorf("Expected no limit, got %d", got)
+	}
+}
+
+func TestValidationMiddlewareStreamsBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Without a Request type the handler gets the body as it came in
+	body := io.NopCloser(strings.NewReader("upload"))
+	var got io.ReadCloser
+	handler := ValidationMiddleware(Route{})(func(c *gin.Context) error {
+		got = c.Request.Body
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/upload", body)
+	c.Request.Body = body
+	if err := handler(c); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got != body {
+		t.Errorf("Expected the body to be passed through untouched")
+	}
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/johansundell/template-service/maintenance"
+	"github.com/johansundell/template-service/tracing"
+)
+
+// RecordMaintenance adds a maintenance mode toggle to the audit trail
+func (s *Storage) RecordMaintenance(ctx context.Context, e maintenance.Event) (err error) {
+	ctx, span := s.startSpan(ctx, "INSERT", "maintenance_audit")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO maintenance_audit (enabled, reason, retry_after, actor, label, source, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.Enabled, e.Reason, e.RetryAfter, e.Actor, e.Label, e.Source, e.CreatedAt.UnixNano())
+	return err
+}
+
+// MaintenanceEvents returns the latest entries of the audit trail, newest first
+func (s *Storage) MaintenanceEvents(ctx context.Context, limit int) (events []maintenance.Event, err error) {
+	ctx, span := s.startSpan(ctx, "SELECT", "maintenance_audit")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, enabled, COALESCE(reason, ''), COALESCE(retry_after, 0), COALESCE(actor, ''), COALESCE(label, ''), source, created_at
+		FROM maintenance_audit ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events = []maintenance.Event{}
+	for rows.Next() {
+		var e maintenance.Event
+		var created int64
+		if err := rows.Scan(&e.ID, &e.Enabled, &e.Reason, &e.RetryAfter, &e.Actor, &e.Label, &e.Source, &created); err != nil {
+			return nil, err
+		}
+		e.CreatedAt = time.Unix(0, created)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// LastMaintenanceEvent returns the latest toggle made through the API, which
+// is what maintenance mode resumes with after a restart
+func (s *Storage) LastMaintenanceEvent(ctx context.Context) (maintenance.Event, error) {
+	var e maintenance.Event
+	var created int64
+	err := s.db.QueryRowContext(ctx, `SELECT id, enabled, COALESCE(reason, ''), COALESCE(retry_after, 0), COALESCE(actor, ''), COALESCE(label, ''), source, created_at
+		FROM maintenance_audit WHERE source = ? ORDER BY id DESC LIMIT 1`, maintenance.SourceAPI).
+		Scan(&e.ID, &e.Enabled, &e.Reason, &e.RetryAfter, &e.Actor, &e.Label, &e.Source, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return maintenance.Event{}, nil
+	}
+	if err != nil {
+		return maintenance.Event{}, err
+	}
+	e.CreatedAt = time.Unix(0, created)
+	return e, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/johansundell/template-service/maintenance"
+)
+
+func TestMaintenanceAudit(t *testing.T) {
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	s := NewStorage(db)
+	ctx := context.Background()
+
+	if last, err := s.LastMaintenanceEvent(ctx); err != nil || last.ID != 0 {
+		t.Fatalf("Expected no events, got %+v, %v", last, err)
+	}
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []maintenance.Event{
+		{Enabled: true, Reason: "upgrade", RetryAfter: 600, Actor: "10.0.0.7", Label: "alice", Source: maintenance.SourceAPI, CreatedAt: now},
+		{Enabled: true, Source: maintenance.SourceFile, CreatedAt: now.Add(time.Minute)},
+	}
+	for _, e := range events {
+		if err := s.RecordMaintenance(ctx, e); err != nil {
+			t.Fatalf("RecordMaintenance failed: %v", err)
+		}
+	}
+
+	got, err := s.MaintenanceEvents(ctx, 10)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected 2 events, got %d, %v", len(got), err)
+	}
+	if got[0].Source != maintenance.SourceFile || !got[1].CreatedAt.Equal(now) {
+		t.Errorf("Expected the newest event first, got %+v", got)
+	}
+
+	last, err := s.LastMaintenanceEvent(ctx)
+	if err != nil || !last.Enabled || last.Reason != "upgrade" || last.Actor != "10.0.0.7" || last.Label != "alice" || last.RetryAfter != 600 {
+		t.Errorf("Expected the API event, got %+v, %v", last, err)
+	}
+}
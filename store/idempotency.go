@@ -0,0 +1,129 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/johansundell/template-service/idempotency"
+	"github.com/johansundell/template-service/tracing"
+)
+
+// IdempotencyStore keeps idempotency keys in the database, so that several
+// instances sharing a MySQL database replay each other's responses
+type IdempotencyStore struct {
+	s   *Storage
+	now func() time.Time
+}
+
+// NewIdempotencyStore creates an idempotency store on top of s
+func NewIdempotencyStore(s *Storage) *IdempotencyStore {
+	return &IdempotencyStore{s: s, now: time.Now}
+}
+
+// Begin claims key when it is new or expired, otherwise it returns its record.
+// The claim relies on the primary key, so only one caller can insert a key,
+// and an expired key is only taken over by the caller whose update still
+// sees the expiry it read.
+func (i *IdempotencyStore) Begin(ctx context.Context, key, requestHash string, lock time.Duration) (rec idempotency.Record, claimed bool, err error) {
+	ctx, span := i.s.startSpan(ctx, "INSERT", "idempotency_keys")
+	defer func() { tracing.End(span, err) }()
+
+	insert := `INSERT INTO idempotency_keys (idem_key, request_hash, status, expires_at) VALUES (?, ?, 0, ?)
+		ON CONFLICT(idem_key) DO NOTHING`
+	if i.s.dialect == DialectMySQL {
+		insert = `INSERT IGNORE INTO idempotency_keys (idem_key, request_hash, status, expires_at) VALUES (?, ?, 0, ?)`
+	}
+
+	// A key can be deleted or taken over between the statements, a few
+	// attempts are enough to settle who owns it
+	for attempt := 0; attempt < 3; attempt++ {
+		now := i.now()
+		claim := idempotency.Record{RequestHash: requestHash}
+
+		res, err := i.s.db.ExecContext(ctx, insert, key, requestHash, now.Add(lock).UnixNano())
+		if err != nil {
+			return idempotency.Record{}, false, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return idempotency.Record{}, false, err
+		} else if n == 1 {
+			return claim, true, nil
+		}
+
+		var status int
+		var headers sql.NullString
+		var body []byte
+		var expires int64
+		err = i.s.db.QueryRowContext(ctx, `SELECT request_hash, status, headers, body, expires_at
+			FROM idempotency_keys WHERE idem_key = ?`, key).Scan(&rec.RequestHash, &status, &headers, &body, &expires)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return idempotency.Record{}, false, err
+		}
+
+		if expires <= now.UnixNano() {
+			res, err := i.s.db.ExecContext(ctx, `UPDATE idempotency_keys
+				SET request_hash = ?, status = 0, headers = NULL, body = NULL, expires_at = ?
+				WHERE idem_key = ? AND expires_at = ?`, requestHash, now.Add(lock).UnixNano(), key, expires)
+			if err != nil {
+				return idempotency.Record{}, false, err
+			}
+			if n, err := res.RowsAffected(); err != nil {
+				return idempotency.Record{}, false, err
+			} else if n == 1 {
+				return claim, true, nil
+			}
+			continue
+		}
+
+		if status != 0 {
+			r := &idempotency.Response{Status: status, Header: http.Header{}, Body: body}
+			if headers.Valid && headers.String != "" {
+				if err := json.Unmarshal([]byte(headers.String), &r.Header); err != nil {
+					return idempotency.Record{}, false, err
+				}
+			}
+			rec.Response = r
+		}
+		return rec, false, nil
+	}
+	return idempotency.Record{}, false, idempotency.ErrInProgress
+}
+
+// Complete stores the response of a claimed key
+func (i *IdempotencyStore) Complete(ctx context.Context, key string, res idempotency.Response, ttl time.Duration) (err error) {
+	ctx, span := i.s.startSpan(ctx, "UPDATE", "idempotency_keys")
+	defer func() { tracing.End(span, err) }()
+
+	headers, err := json.Marshal(res.Header)
+	if err != nil {
+		return err
+	}
+	_, err = i.s.db.ExecContext(ctx, `UPDATE idempotency_keys SET status = ?, headers = ?, body = ?, expires_at = ?
+		WHERE idem_key = ?`, res.Status, string(headers), res.Body, i.now().Add(ttl).UnixNano(), key)
+	return err
+}
+
+// Release drops a claim that has no response yet
+func (i *IdempotencyStore) Release(ctx context.Context, key string) (err error) {
+	ctx, span := i.s.startSpan(ctx, "DELETE", "idempotency_keys")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = i.s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE idem_key = ? AND status = 0`, key)
+	return err
+}
+
+// Prune deletes keys that expired before the given time
+func (i *IdempotencyStore) Prune(before time.Time) (int64, error) {
+	res, err := i.s.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
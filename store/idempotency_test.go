@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johansundell/template-service/idempotency"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	db, err := NewSqliteDatabase(filepath.Join(t.TempDir(), "idempotency.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewIdempotencyStore(NewStorage(db))
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, claimed, err := s.Begin(ctx, "key", "a", time.Minute); err != nil || !claimed {
+		t.Fatalf("Expected the first request to claim the key, got %v, %v", claimed, err)
+	}
+	rec, claimed, err := s.Begin(ctx, "key", "a", time.Minute)
+	if err != nil || claimed || rec.Response != nil {
+		t.Fatalf("Expected a pending record, got %+v, %v, %v", rec, claimed, err)
+	}
+
+	res := idempotency.Response{Status: 201, Header: http.Header{"Location": {"/things/1"}}, Body: []byte(`{"id":1}`)}
+	if err := s.Complete(ctx, "key", res, time.Hour); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	rec, claimed, err = s.Begin(ctx, "key", "b", time.Minute)
+	if err != nil || claimed {
+		t.Fatalf("Expected the stored record, got %v, %v", claimed, err)
+	}
+	if rec.RequestHash != "a" || rec.Response == nil || rec.Response.Status != 201 ||
+		rec.Response.Header.Get("Location") != "/things/1" || string(rec.Response.Body) != `{"id":1}` {
+		t.Errorf("Unexpected record %+v, %+v", rec, rec.Response)
+	}
+
+	// Releasing a completed key keeps it
+	if err := s.Release(ctx, "key"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, claimed, _ := s.Begin(ctx, "key", "a", time.Minute); claimed {
+		t.Errorf("Expected a completed key to survive Release")
+	}
+
+	// Releasing a pending key lets the next request claim it
+	s.Begin(ctx, "other", "a", time.Minute)
+	if err := s.Release(ctx, "other"); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, claimed, _ := s.Begin(ctx, "other", "b", time.Minute); !claimed {
+		t.Errorf("Expected a released key to be claimable")
+	}
+
+	// Expired keys are taken over
+	now = now.Add(2 * time.Hour)
+	if rec, claimed, _ := s.Begin(ctx, "key", "b", time.Minute); !claimed || rec.RequestHash != "b" {
+		t.Errorf("Expected an expired key to be claimable, got %+v, %v", rec, claimed)
+	}
+
+	removed, err := s.Prune(now.Add(time.Hour))
+	if err != nil || removed != 2 {
+		t.Errorf("Expected 2 keys to be pruned, got %d, %v", removed, err)
+	}
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/johansundell/template-service/types"
+)
+
+// ErrLogQueueFull is returned when a log entry is dropped because the queue is full
+var ErrLogQueueFull = errors.New("request log queue is full")
+
+// ErrLogWriterClosed is returned when a log entry is written after Close
+var ErrLogWriterClosed = errors.New("request log writer is closed")
+
+// LogSink receives finished request logs
+type LogSink interface {
+	SaveLog(l types.UsageLog) error
+}
+
+// LogWriter writes request logs to the storage from a background worker,
+// so a slow database never holds up a response.
+type LogWriter struct {
+	sink    LogSink
+	queue   chan types.UsageLog
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewLogWriter starts a log writer with a queue holding up to size entries
+func NewLogWriter(sink LogSink, size int) *LogWriter {
+	if size < 1 {
+		size = 1
+	}
+	w := &LogWriter{
+		sink:  sink,
+		queue: make(chan types.UsageLog, size),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *LogWriter) run() {
+	defer close(w.done)
+	for l := range w.queue {
+		if err := w.sink.SaveLog(l); err != nil {
+			log.Println("Failed to write request log:", err)
+		}
+	}
+}
+
+// SaveLog queues the log entry without blocking. Entries that don't fit in the queue are dropped.
+func (w *LogWriter) SaveLog(l types.UsageLog) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return ErrLogWriterClosed
+	}
+
+	select {
+	case w.queue <- l:
+		return nil
+	default:
+		w.dropped.Add(1)
+		return ErrLogQueueFull
+	}
+}
+
+// Len returns the number of entries waiting to be written
+func (w *LogWriter) Len() int {
+	return len(w.queue)
+}
+
+// Cap returns the size of the queue
+func (w *LogWriter) Cap() int {
+	return cap(w.queue)
+}
+
+// Dropped returns the number of entries that were never written
+func (w *LogWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close stops accepting new entries and waits for the queue to drain, or for ctx to expire
+func (w *LogWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johansundell/template-service/types"
+)
+
+type blockingSink struct {
+	mu      sync.Mutex
+	release chan struct{}
+	saved   []types.UsageLog
+}
+
+func (b *blockingSink) SaveLog(l types.UsageLog) error {
+	<-b.release
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.saved = append(b.saved, l)
+	return nil
+}
+
+func TestLogWriterDrainsOnClose(t *testing.T) {
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	w := NewLogWriter(NewStorage(db), 10)
+	for i := 0; i < 5; i++ {
+		if err := w.SaveLog(types.UsageLog{Status: 200, Method: "GET", Endpoint: "/test", CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("SaveLog failed: %v", err)
+		}
+	}
+
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM request_logs").Scan(&count); err != nil {
+		t.Fatalf("Failed to query logs: %v", err)
+	}
+	if count != 5 {
+		t.Errorf("Expected 5 log entries, got %d", count)
+	}
+
+	if err := w.SaveLog(types.UsageLog{}); err != ErrLogWriterClosed {
+		t.Errorf("Expected ErrLogWriterClosed, got %v", err)
+	}
+}
+
+func TestLogWriterDropsWhenFull(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	w := NewLogWriter(sink, 1)
+
+	// The worker holds one entry while blocked, the queue holds one more
+	var dropped int
+	for i := 0; i < 5; i++ {
+		if err := w.SaveLog(types.UsageLog{ID: i}); err == ErrLogQueueFull {
+			dropped++
+		}
+	}
+	if dropped == 0 || w.Dropped() != uint64(dropped) {
+		t.Errorf("Expected dropped entries to be counted, got %d dropped and counter %d", dropped, w.Dropped())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := w.Close(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expected close to time out while the sink is blocked, got %v", err)
+	}
+
+	close(sink.release)
+	if err := w.Close(context.Background()); err != nil {
+		t.Errorf("Expected close to finish once the sink is released, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/johansundell/template-service/tracing"
+)
+
+// ErrNotSupported is returned for maintenance tasks the database can't do by itself
+var ErrNotSupported = errors.New("not supported by this database")
+
+// Backup writes a consistent copy of the database to path. Only SQLite is
+// supported, MySQL databases should be backed up with mysqldump.
+func (s *Storage) Backup(path string) error {
+	if s.dialect != DialectSQLite {
+		return ErrNotSupported
+	}
+	_, err := s.db.Exec(`VACUUM INTO ?`, path)
+	return err
+}
+
+// Vacuum reclaims unused space and defragments the request log table
+func (s *Storage) Vacuum() error {
+	if s.dialect == DialectMySQL {
+		_, err := s.db.Exec(`OPTIMIZE TABLE request_logs`)
+		return err
+	}
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
+
+// PruneLogs deletes request logs created before the given time and returns how many were removed
+func (s *Storage) PruneLogs(before time.Time) (int64, error) {
+	return s.PruneLogsContext(context.Background(), before)
+}
+
+// PruneLogsContext is PruneLogs traced as part of ctx
+func (s *Storage) PruneLogsContext(ctx context.Context, before time.Time) (n int64, err error) {
+	ctx, span := s.startSpan(ctx, "DELETE", "request_logs")
+	defer func() { tracing.End(span, err) }()
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM request_logs WHERE created_at < ?`, before.Format(time.RFC3339))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneLogs(t *testing.T) {
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	s := NewStorage(db)
+
+	old := time.Now().AddDate(0, 0, -40)
+	s.LogRequest(200, "GET", "", "/old", old.Format(time.RFC3339), "{}", "{}")
+	s.LogRequest(200, "GET", "", "/new", time.Now().Format(time.RFC3339), "{}", "{}")
+
+	removed, err := s.PruneLogs(time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("PruneLogs failed: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("Expected 1 log to be removed, got %d", removed)
+	}
+}
+
+func TestBackupAndVacuum(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewSqliteDatabase(filepath.Join(dir, "source.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	s := NewStorage(db)
+	s.LogRequest(200, "GET", "", "/test", time.Now().Format(time.RFC3339), "{}", "{}")
+
+	if err := s.Vacuum(); err != nil {
+		t.Errorf("Vacuum failed: %v", err)
+	}
+
+	backup := filepath.Join(dir, "backup.db")
+	if err := s.Backup(backup); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := os.Stat(backup); err != nil {
+		t.Fatalf("Backup file missing: %v", err)
+	}
+
+	copyDB, err := NewSqliteDatabase(backup)
+	if err != nil {
+		t.Fatalf("Failed to open backup: %v", err)
+	}
+	defer copyDB.Close()
+	var count int
+	copyDB.QueryRow("SELECT COUNT(*) FROM request_logs").Scan(&count)
+	if count != 1 {
+		t.Errorf("Expected 1 log entry in the backup, got %d", count)
+	}
+}
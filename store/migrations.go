@@ -0,0 +1,271 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/johansundell/template-service/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	DialectSQLite = "sqlite"
+	DialectMySQL  = "mysql"
+)
+
+type migration struct {
+	version int
+	name    string
+	sqlite  string
+	mysql   string
+}
+
+// migrations are applied in order and must never be edited once released, add a new one instead
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create request_logs",
+		sqlite: `CREATE TABLE IF NOT EXISTS request_logs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		status INTEGER,
+		method TEXT,
+		error TEXT,
+		endpoint TEXT,
+		created_at DATETIME,
+		response TEXT,
+		request TEXT
+	)`,
+		mysql: `CREATE TABLE IF NOT EXISTS request_logs (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		status INT,
+		method TEXT,
+		error TEXT,
+		endpoint TEXT,
+		created_at DATETIME,
+		response TEXT,
+		request TEXT
+	)`,
+	},
+	{
+		version: 2,
+		name:    "add client_identity to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN client_identity TEXT`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN client_identity TEXT`,
+	},
+	{
+		version: 3,
+		name:    "add timeout_ms to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN timeout_ms INTEGER`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN timeout_ms BIGINT`,
+	},
+	{
+		version: 4,
+		name:    "add timed_out to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN timed_out INTEGER`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN timed_out TINYINT(1)`,
+	},
+	{
+		version: 5,
+		name:    "create rate_limits",
+		sqlite: `CREATE TABLE IF NOT EXISTS rate_limits (
+		bucket_key TEXT PRIMARY KEY,
+		tokens REAL NOT NULL,
+		updated_at INTEGER NOT NULL
+	)`,
+		mysql: `CREATE TABLE IF NOT EXISTS rate_limits (
+		bucket_key VARCHAR(255) PRIMARY KEY,
+		tokens DOUBLE NOT NULL,
+		updated_at BIGINT NOT NULL
+	)`,
+	},
+	{
+		version: 6,
+		name:    "add request_id to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN request_id TEXT`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN request_id VARCHAR(128)`,
+	},
+	{
+		version: 7,
+		name:    "index request_logs by request_id",
+		sqlite:  `CREATE INDEX IF NOT EXISTS idx_request_logs_request_id ON request_logs (request_id)`,
+		mysql:   `CREATE INDEX idx_request_logs_request_id ON request_logs (request_id)`,
+	},
+	{
+		version: 8,
+		name:    "add trace_id to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN trace_id TEXT`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN trace_id VARCHAR(32)`,
+	},
+	{
+		version: 9,
+		name:    "add stack to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN stack TEXT`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN stack TEXT`,
+	},
+	{
+		version: 10,
+		name:    "create idempotency_keys",
+		sqlite: `CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idem_key TEXT PRIMARY KEY,
+		request_hash TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		headers TEXT,
+		body BLOB,
+		expires_at INTEGER NOT NULL
+	)`,
+		mysql: `CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idem_key CHAR(64) PRIMARY KEY,
+		request_hash CHAR(64) NOT NULL,
+		status INT NOT NULL DEFAULT 0,
+		headers TEXT,
+		body LONGBLOB,
+		expires_at BIGINT NOT NULL
+	)`,
+	},
+	{
+		version: 11,
+		name:    "add client_ip to request_logs",
+		sqlite:  `ALTER TABLE request_logs ADD COLUMN client_ip TEXT`,
+		mysql:   `ALTER TABLE request_logs ADD COLUMN client_ip VARCHAR(45)`,
+	},
+	{
+		version: 12,
+		name:    "create maintenance_audit",
+		sqlite: `CREATE TABLE IF NOT EXISTS maintenance_audit (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		enabled INTEGER NOT NULL,
+		reason TEXT,
+		retry_after INTEGER,
+		actor TEXT,
+		source TEXT NOT NULL,
+		created_at INTEGER NOT NULL
+	)`,
+		mysql: `CREATE TABLE IF NOT EXISTS maintenance_audit (
+		id INT AUTO_INCREMENT PRIMARY KEY,
+		enabled TINYINT(1) NOT NULL,
+		reason TEXT,
+		retry_after INT,
+		actor VARCHAR(255),
+		source VARCHAR(16) NOT NULL,
+		created_at BIGINT NOT NULL
+	)`,
+	},
+	{
+		version: 13,
+		name:    "add label to maintenance_audit",
+		sqlite:  `ALTER TABLE maintenance_audit ADD COLUMN label TEXT`,
+		mysql:   `ALTER TABLE maintenance_audit ADD COLUMN label VARCHAR(255)`,
+	},
+}
+
+// dialectOf tells which SQL dialect the database speaks
+func dialectOf(db *sql.DB) string {
+	if _, ok := db.Driver().(*mysql.MySQLDriver); ok {
+		return DialectMySQL
+	}
+	return DialectSQLite
+}
+
+// migrationLock names the MySQL lock held while migrating
+const migrationLock = "template-service.schema_migrations"
+
+// migrationLockWait is how long Migrate waits for another instance to finish migrating
+const migrationLockWait = 60 * time.Second
+
+// Migrate brings the database schema up to date and returns the versions it
+// applied. Each migration is applied in a transaction together with its
+// version, and on MySQL, where DDL commits on its own, a named lock keeps
+// instances starting at the same time from migrating at once.
+func Migrate(db *sql.DB) (applied []int, err error) {
+	dialect := dialectOf(db)
+	ctx, span := tracing.StartDB(context.Background(), dialect, "MIGRATE", "schema_migrations")
+	defer func() {
+		span.SetAttributes(attribute.IntSlice("db.migrations.applied", applied))
+		tracing.End(span, err)
+	}()
+
+	// The lock belongs to a connection, so everything runs on this one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if dialect == DialectMySQL {
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, ?)`, migrationLock, int(migrationLockWait.Seconds())).Scan(&locked); err != nil {
+			return nil, err
+		}
+		if locked.Int64 != 1 {
+			return nil, fmt.Errorf("another instance has been migrating the database for %s", migrationLockWait)
+		}
+		defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, migrationLock)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		applied_at DATETIME
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range migrations {
+		ok, err := applyMigration(ctx, conn, dialect, m)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied = append(applied, m.version)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration applies m and records its version in one transaction,
+// reporting false when it was applied before
+func applyMigration(ctx context.Context, conn *sql.Conn, dialect string, m migration) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Checked inside the transaction, another process may have just applied it
+	var done int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.version).Scan(&done); err != nil {
+		return false, err
+	}
+	if done > 0 {
+		return false, nil
+	}
+
+	stmt := m.sqlite
+	if dialect == DialectMySQL {
+		stmt = m.mysql
+	}
+	if _, err := tx.ExecContext(ctx, stmt); err != nil {
+		return false, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		m.version, m.name, time.Now().Format(time.RFC3339)); err != nil {
+		return false, fmt.Errorf("record migration %d: %w", m.version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("migration %d (%s): %w", m.version, m.name, err)
+	}
+	return true, nil
+}
+
+// SchemaVersion returns the latest migration applied to the database
+func SchemaVersion(db *sql.DB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	version, err := SchemaVersion(db)
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != migrations[len(migrations)-1].version {
+		t.Errorf("Expected schema version %d, got %d", migrations[len(migrations)-1].version, version)
+	}
+
+	// Running again is a no-op
+	applied, err := Migrate(db)
+	if err != nil {
+		t.Fatalf("Migrate failed: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Expected no migrations to be applied, got %v", applied)
+	}
+}
+
+func TestMigrateRollsBackFailedStep(t *testing.T) {
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	latest := migrations[len(migrations)-1].version
+
+	saved := migrations
+	defer func() { migrations = saved }()
+	migrations = append(slices.Clone(saved), migration{
+		version: latest + 1,
+		name:    "broken",
+		sqlite:  `CREATE TABLE half_done (id INTEGER); INSERT INTO missing_table VALUES (1)`,
+	})
+
+	if _, err := Migrate(db); err == nil {
+		t.Fatalf("Expected the broken migration to fail")
+	}
+	if version, _ := SchemaVersion(db); version != latest {
+		t.Errorf("Expected schema version %d, got %d", latest, version)
+	}
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n)
+	if n != 0 {
+		t.Errorf("Expected the failed migration to be rolled back")
+	}
+}
@@ -6,23 +6,20 @@ import (
 	"github.com/go-sql-driver/mysql"
 )
 
+// OpenMySQLStorage opens the database without touching the schema
+func OpenMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
+	return sql.Open("mysql", cfg.FormatDSN())
+}
+
+// NewMySQLStorage opens the database and migrates it to the latest schema
 func NewMySQLStorage(cfg mysql.Config) (*sql.DB, error) {
-	db, err := sql.Open("mysql", cfg.FormatDSN())
+	db, err := OpenMySQLStorage(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS request_logs (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		status INT,
-		method TEXT,
-		error TEXT,
-		endpoint TEXT,
-		created_at DATETIME,
-		response TEXT,
-		request TEXT
-	)`)
-	if err != nil {
+	if _, err := Migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/johansundell/template-service/ratelimit"
+	"github.com/johansundell/template-service/tracing"
+)
+
+// RateLimitStore keeps rate limit buckets in the database, so that several
+// instances sharing a MySQL database share their limits
+type RateLimitStore struct {
+	s *Storage
+	// mu serializes updates on SQLite, which can't lock a single row
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// NewRateLimitStore creates a rate limit store on top of s
+func NewRateLimitStore(s *Storage) *RateLimitStore {
+	return &RateLimitStore{s: s, now: time.Now}
+}
+
+// Allow takes a token from the bucket for key
+func (r *RateLimitStore) Allow(ctx context.Context, key string, l ratelimit.Limit) (res ratelimit.Result, err error) {
+	if !l.Enabled() {
+		return ratelimit.Result{}, ratelimit.ErrDisabled
+	}
+	ctx, span := r.s.startSpan(ctx, "UPSERT", "rate_limits")
+	defer func() { tracing.End(span, err) }()
+
+	if r.s.dialect == DialectSQLite {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+
+	tx, err := r.s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT tokens, updated_at FROM rate_limits WHERE bucket_key = ?`
+	if r.s.dialect == DialectMySQL {
+		query += ` FOR UPDATE`
+	}
+	var b ratelimit.Bucket
+	var updated int64
+	err = tx.QueryRowContext(ctx, query, key).Scan(&b.Tokens, &updated)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return ratelimit.Result{}, err
+	default:
+		b.Updated = time.Unix(0, updated)
+	}
+
+	b, res = ratelimit.Take(b, l, r.now())
+
+	upsert := `INSERT INTO rate_limits (bucket_key, tokens, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(bucket_key) DO UPDATE SET tokens = excluded.tokens, updated_at = excluded.updated_at`
+	if r.s.dialect == DialectMySQL {
+		upsert = `INSERT INTO rate_limits (bucket_key, tokens, updated_at) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE tokens = VALUES(tokens), updated_at = VALUES(updated_at)`
+	}
+	if _, err := tx.ExecContext(ctx, upsert, key, b.Tokens, b.Updated.UnixNano()); err != nil {
+		return ratelimit.Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+// Prune deletes buckets that haven't been used since before
+func (r *RateLimitStore) Prune(before time.Time) (int64, error) {
+	res, err := r.s.db.Exec(`DELETE FROM rate_limits WHERE updated_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
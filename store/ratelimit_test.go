@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/johansundell/template-service/ratelimit"
+)
+
+func TestRateLimitStore(t *testing.T) {
+	db, err := NewSqliteDatabase(filepath.Join(t.TempDir(), "ratelimit.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := NewRateLimitStore(NewStorage(db))
+	r.now = func() time.Time { return now }
+	l := ratelimit.Limit{Requests: 1, Per: time.Minute}
+	ctx := context.Background()
+
+	res, err := r.Allow(ctx, "client", l)
+	if err != nil || !res.Allowed {
+		t.Fatalf("Expected the first request to be allowed, got %+v, %v", res, err)
+	}
+	res, err = r.Allow(ctx, "client", l)
+	if err != nil || res.Allowed {
+		t.Fatalf("Expected the second request to be limited, got %+v, %v", res, err)
+	}
+	if res.RetryAfter != time.Minute {
+		t.Errorf("Expected to retry after a minute, got %s", res.RetryAfter)
+	}
+
+	now = now.Add(time.Minute)
+	if res, _ := r.Allow(ctx, "client", l); !res.Allowed {
+		t.Errorf("Expected the bucket to refill")
+	}
+
+	removed, err := r.Prune(now.Add(time.Second))
+	if err != nil || removed != 1 {
+		t.Errorf("Expected 1 bucket to be pruned, got %d, %v", removed, err)
+	}
+}
@@ -9,23 +9,20 @@ import (
 	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
+// OpenSqliteDatabase opens the database without touching the schema
+func OpenSqliteDatabase(file string) (*sql.DB, error) {
+	return sql.Open("sqlite3", "file:"+file)
+}
+
+// NewSqliteDatabase opens the database and migrates it to the latest schema
 func NewSqliteDatabase(file string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", "file:"+file)
+	db, err := OpenSqliteDatabase(file)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS request_logs (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		status INTEGER,
-		method TEXT,
-		error TEXT,
-		endpoint TEXT,
-		created_at DATETIME,
-		response TEXT,
-		request TEXT
-	)`)
-	if err != nil {
+	if _, err := Migrate(db); err != nil {
+		db.Close()
 		return nil, err
 	}
 
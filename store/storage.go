@@ -1,50 +1,109 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
+	"github.com/johansundell/template-service/tracing"
 	"github.com/johansundell/template-service/types"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Storage struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect string
 }
 
 func NewStorage(db *sql.DB) *Storage {
-	return &Storage{db: db}
+	return &Storage{db: db, dialect: dialectOf(db)}
+}
+
+// DB returns the underlying database, e.g. for connection pool stats
+func (s *Storage) DB() *sql.DB {
+	return s.db
 }
 
 type Store interface {
 	Ping() error
 	GetLogs(from, to time.Time) ([]types.UsageLog, error)
+	GetLogsByRequestID(ctx context.Context, id string) ([]types.UsageLog, error)
 	LogRequest(status int, method, errStr, endpoint string, createdAt string, response, request string) error
+	SaveLog(l types.UsageLog) error
+}
+
+// startSpan starts a trace span for a query on table
+func (s *Storage) startSpan(ctx context.Context, operation, table string) (context.Context, trace.Span) {
+	return tracing.StartDB(ctx, s.dialect, operation, table)
 }
 
 func (s *Storage) Ping() error {
 	return s.db.Ping()
 }
 
+// PingContext checks the database connection, giving up when ctx expires
+func (s *Storage) PingContext(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
 func (s *Storage) LogRequest(status int, method, errStr, endpoint string, createdAt string, response, request string) error {
 	_, err := s.db.Exec(`INSERT INTO request_logs (status, method, error, endpoint, created_at, response, request) VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		status, method, errStr, endpoint, createdAt, response, request)
 	return err
 }
 
+// SaveLog writes a finished request log, traced as part of the request it was made for
+func (s *Storage) SaveLog(l types.UsageLog) (err error) {
+	ctx := trace.ContextWithSpanContext(context.Background(), l.SpanContext)
+	ctx, span := s.startSpan(ctx, "INSERT", "request_logs")
+	defer func() { tracing.End(span, err) }()
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO request_logs (status, method, error, endpoint, created_at, response, request, client_identity, timeout_ms, timed_out, request_id, trace_id, stack, client_ip) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		l.Status, l.Method, l.Error, l.Endpoint, l.CreatedAt.Format(time.RFC3339), string(l.Response), string(l.Request), l.ClientIdentity, l.TimeoutMs, l.TimedOut, l.RequestID, l.TraceID, l.Stack, l.ClientIP)
+	return err
+}
+
+// logColumns are the request_logs columns read by scanLogs
+const logColumns = `id, status, method, error, endpoint, created_at, response, request, COALESCE(client_identity, ''), COALESCE(timeout_ms, 0), COALESCE(timed_out, 0), COALESCE(request_id, ''), COALESCE(trace_id, ''), COALESCE(stack, ''), COALESCE(client_ip, '')`
+
 func (s *Storage) GetLogs(from, to time.Time) ([]types.UsageLog, error) {
-	rows, err := s.db.Query(`SELECT id, status, method, error, endpoint, created_at, response, request FROM request_logs WHERE created_at BETWEEN ? AND ?`, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return s.GetLogsContext(context.Background(), from, to)
+}
+
+// GetLogsContext returns the logs created between from and to, traced as part of ctx
+func (s *Storage) GetLogsContext(ctx context.Context, from, to time.Time) (logs []types.UsageLog, err error) {
+	ctx, span := s.startSpan(ctx, "SELECT", "request_logs")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+logColumns+` FROM request_logs WHERE created_at BETWEEN ? AND ?`, from.Format(time.RFC3339), to.Format(time.RFC3339))
 	if err != nil {
 		return nil, err
 	}
+	return scanLogs(rows)
+}
+
+// GetLogsByRequestID returns the logs written for a request ID
+func (s *Storage) GetLogsByRequestID(ctx context.Context, id string) (logs []types.UsageLog, err error) {
+	ctx, span := s.startSpan(ctx, "SELECT", "request_logs")
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+logColumns+` FROM request_logs WHERE request_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, err
+	}
+	return scanLogs(rows)
+}
+
+func scanLogs(rows *sql.Rows) ([]types.UsageLog, error) {
 	defer rows.Close()
 
 	var logs []types.UsageLog
 	for rows.Next() {
 		var l types.UsageLog
-		if err := rows.Scan(&l.ID, &l.Status, &l.Method, &l.Error, &l.Endpoint, &l.CreatedAt, &l.Response, &l.Request); err != nil {
+		if err := rows.Scan(&l.ID, &l.Status, &l.Method, &l.Error, &l.Endpoint, &l.CreatedAt, &l.Response, &l.Request, &l.ClientIdentity, &l.TimeoutMs, &l.TimedOut, &l.RequestID, &l.TraceID, &l.Stack, &l.ClientIP); err != nil {
 			return nil, err
 		}
 		logs = append(logs, l)
 	}
-	return logs, nil
+	return logs, rows.Err()
 }
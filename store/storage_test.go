@@ -1,12 +1,17 @@
 package store
 
 import (
+	"context"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/johansundell/template-service/types"
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func TestLogRequest(t *testing.T) {
@@ -39,3 +44,42 @@ func TestLogRequest(t *testing.T) {
 		t.Errorf("Expected 1 log entry, got %d", count)
 	}
 }
+
+func TestLogSpans(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(prev)
+
+	db, err := NewSqliteDatabase(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	s := NewStorage(db)
+
+	_, request := otel.Tracer("test").Start(context.Background(), "GET /items")
+	request.End()
+	err = s.SaveLog(types.UsageLog{Status: 200, Method: "GET", CreatedAt: time.Now(), SpanContext: request.SpanContext()})
+	if err != nil {
+		t.Fatalf("SaveLog failed: %v", err)
+	}
+	if _, err := s.PruneLogs(time.Now().AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("PruneLogs failed: %v", err)
+	}
+
+	names := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range rec.Ended() {
+		names[span.Name()] = span
+	}
+	if span := names["MIGRATE schema_migrations"]; span == nil {
+		t.Errorf("Expected a span for the migrations")
+	}
+	insert := names["INSERT request_logs"]
+	if insert == nil || insert.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("Expected the log write to be a child of the request span")
+	}
+	if names["DELETE request_logs"] == nil {
+		t.Errorf("Expected a span for pruning the logs")
+	}
+}
@@ -0,0 +1,176 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Config describes how the server terminates TLS
+type Config struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3", empty means 1.2
+	MinVersion string
+	// CipherPolicy is "default", "modern", "compatible" or a comma separated list of cipher suite names
+	CipherPolicy string
+	// ClientCAFile enables mutual TLS, client certificates are verified against this bundle
+	ClientCAFile string
+	// ClientAuth is "none", "request", "verify-if-given" or "require", empty means "require" when a CA is set
+	ClientAuth string
+}
+
+// Enabled reports whether a certificate is configured
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// modernSuites are forward secret AEAD suites, TLS 1.3 suites are always enabled by Go
+var modernSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// compatibleSuites adds CBC suites for older clients
+var compatibleSuites = append(append([]uint16{}, modernSuites...),
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+)
+
+// NewServerConfig builds a server TLS config whose certificate is reloaded when the files change
+func NewServerConfig(cfg Config) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("both a certificate and a key file are required")
+	}
+
+	reloader, err := NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	minVersion, err := ParseVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	suites, err := ParseCipherPolicy(cfg.CipherPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	tlsConfig.ClientAuth, err = parseClientAuth(cfg.ClientAuth, tlsConfig.ClientCAs != nil)
+	if err != nil {
+		return nil, err
+	}
+	return tlsConfig, nil
+}
+
+// ParseVersion converts "1.2" or "1.3" to the tls package constant
+func ParseVersion(v string) (uint16, error) {
+	switch strings.TrimSpace(v) {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, use 1.2 or 1.3", v)
+}
+
+// ParseCipherPolicy returns the cipher suites for a named policy or a list of suite names.
+// A nil result means Go's defaults.
+func ParseCipherPolicy(policy string) ([]uint16, error) {
+	switch strings.ToLower(strings.TrimSpace(policy)) {
+	case "", "default":
+		return nil, nil
+	case "modern":
+		return modernSuites, nil
+	case "compatible":
+		return compatibleSuites, nil
+	}
+
+	byName := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		byName[s.Name] = s.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(policy, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+func parseClientAuth(mode string, haveCA bool) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "":
+		if haveCA {
+			return tls.RequireAndVerifyClientCert, nil
+		}
+		return tls.NoClientCert, nil
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify-if-given":
+		if !haveCA {
+			return 0, errors.New("client auth verify-if-given needs a client CA bundle")
+		}
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		if !haveCA {
+			return 0, errors.New("client auth require needs a client CA bundle")
+		}
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return 0, fmt.Errorf("unknown client auth mode %q", mode)
+}
+
+// ClientIdentity returns the identity of a verified client certificate, or an empty string.
+// The subject common name is preferred, followed by the first DNS name and email address.
+func ClientIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := state.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	}
+	return cert.Subject.String()
+}
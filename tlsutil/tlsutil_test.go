@@ -0,0 +1,119 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCert(t *testing.T, dir, cn string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{cn},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "first.example.com")
+
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	cert, _ := r.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "first.example.com" {
+		t.Errorf("Expected first certificate, got %s", cert.Leaf.Subject.CommonName)
+	}
+
+	writeCert(t, dir, "second.example.com")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	r.lastCheck = time.Time{}
+
+	cert, _ = r.GetCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second.example.com" {
+		t.Errorf("Expected reloaded certificate, got %s", cert.Leaf.Subject.CommonName)
+	}
+}
+
+func TestNewServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "localhost")
+
+	cfg, err := NewServerConfig(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3", ClientCAFile: certFile})
+	if err != nil {
+		t.Fatalf("Failed to build config: %v", err)
+	}
+	if cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", cfg.MinVersion)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Errorf("Expected client certificates to be required when a CA is set, got %v", cfg.ClientAuth)
+	}
+
+	if _, err := NewServerConfig(Config{CertFile: certFile, KeyFile: keyFile, ClientAuth: "require"}); err == nil {
+		t.Errorf("Expected error when requiring client certificates without a CA")
+	}
+	if _, err := NewServerConfig(Config{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"}); err == nil {
+		t.Errorf("Expected error for TLS 1.0")
+	}
+}
+
+func TestParseCipherPolicy(t *testing.T) {
+	suites, err := ParseCipherPolicy("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(suites) != 2 {
+		t.Errorf("Expected 2 suites, got %d", len(suites))
+	}
+
+	if _, err := ParseCipherPolicy("TLS_RSA_WITH_RC4_128_SHA"); err == nil {
+		t.Errorf("Expected insecure suite to be rejected")
+	}
+}
+
+func TestClientIdentity(t *testing.T) {
+	if id := ClientIdentity(nil); id != "" {
+		t.Errorf("Expected no identity without TLS, got %q", id)
+	}
+
+	state := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing-client"}}}},
+	}
+	if id := ClientIdentity(state); id != "billing-client" {
+		t.Errorf("Expected billing-client, got %q", id)
+	}
+}
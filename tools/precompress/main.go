@@ -0,0 +1,80 @@
+// Command precompress writes .gz and .br files next to the compressible
+// files in a directory, so they can be embedded and served as they are.
+//
+//	go run ./tools/precompress assets
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/johansundell/template-service/compression"
+)
+
+// minSize skips files too small to gain anything
+const minSize = 256
+
+func main() {
+	if len(os.Args) != 2 {
+		log.Fatal("usage: precompress <dir>")
+	}
+	cfg := compression.Config{ContentTypes: compression.DefaultContentTypes}
+
+	err := filepath.WalkDir(os.Args[1], func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".br") {
+			return nil
+		}
+		if !cfg.Compressible(mime.TypeByExtension(filepath.Ext(path))) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || len(data) < minSize {
+			return err
+		}
+
+		if err := write(path+".gz", data, func(w io.Writer) io.WriteCloser {
+			gz, _ := gzip.NewWriterLevel(w, gzip.BestCompression)
+			return gz
+		}); err != nil {
+			return err
+		}
+		if err := write(path+".br", data, func(w io.Writer) io.WriteCloser {
+			return brotli.NewWriterLevel(w, brotli.BestCompression)
+		}); err != nil {
+			return err
+		}
+		fmt.Println("compressed", path)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func write(path string, data []byte, newWriter func(io.Writer) io.WriteCloser) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := newWriter(f)
+	if _, err := w.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
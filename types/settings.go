@@ -1,18 +1,88 @@
 package types
 
 type AppSettings struct {
-	Debug         bool   `json:"debug"`
-	Port          string `json:"port"`
-	UseFileSystem bool   `json:"useFileSystem"`
-	Timeout       int    `json:"timeout"`
-	UseMySQL      bool   `json:"useMysql"`
-	UseSqlite     bool   `json:"useSqlite"`
-	AuthToken     string `json:"authToken"`
-	MySqlSettings struct {
+	Debug           bool   `json:"debug"`
+	Port            string `json:"port"`
+	UseFileSystem   bool   `json:"useFileSystem"`
+	Timeout         int    `json:"timeout"`
+	UseMySQL        bool   `json:"useMysql"`
+	UseSqlite       bool   `json:"useSqlite"`
+	AuthToken       string `json:"authToken"`
+	ShutdownTimeout int    `json:"shutdownTimeout"`
+	LogQueueSize    int    `json:"logQueueSize"`
+	HealthTimeout   int    `json:"healthTimeout"`
+	HealthCacheTTL  int    `json:"healthCacheTtl"`
+	MinFreeDiskMB   int    `json:"minFreeDiskMb"`
+	MySqlSettings   struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
 		Host     string `json:"host"`
 		Port     string `json:"port"`
 		Database string `json:"database"`
 	} `json:"mysql"`
+	AdminPort             string `json:"adminPort"`
+	AdminTLS              bool   `json:"adminTls"`
+	UnixSocketMode        string `json:"unixSocketMode"`
+	UnixSocketGroup       string `json:"unixSocketGroup"`
+	SystemdSocket         string `json:"systemdSocket"`
+	AdminSystemdSocket    string `json:"adminSystemdSocket"`
+	HTTP3Enabled          bool   `json:"http3Enabled"`
+	HTTP3Port             string `json:"http3Port"`
+	LogRetentionDays      int    `json:"logRetentionDays"`
+	LogPruneSchedule      string `json:"logPruneSchedule"`
+	JobTimeout            int    `json:"jobTimeout"`
+	JobJitter             int    `json:"jobJitter"`
+	RateLimits            string `json:"rateLimits"`
+	RateLimitBackend      string `json:"rateLimitBackend"`
+	RateLimitPreAuth      string `json:"rateLimitPreAuth"`
+	Compression           bool   `json:"compression"`
+	CompressionMinSize    int    `json:"compressionMinSize"`
+	CompressionTypes      string `json:"compressionTypes"`
+	PanicWebhookURL       string `json:"panicWebhookUrl"`
+	MaxBodyBytes          int64  `json:"maxBodyBytes"`
+	IdempotencyTTL        int    `json:"idempotencyTtl"`
+	CacheMaxBytes         int64  `json:"cacheMaxBytes"`
+	MaintenanceFile       string `json:"maintenanceFile"`
+	MaintenanceRetryAfter int    `json:"maintenanceRetryAfter"`
+	MaintenanceAllow      string `json:"maintenanceAllow"`
+	TLSSettings           struct {
+		CertFile     string `json:"certFile"`
+		KeyFile      string `json:"keyFile"`
+		MinVersion   string `json:"minVersion"`
+		CipherPolicy string `json:"cipherPolicy"`
+		ClientCAFile string `json:"clientCaFile"`
+		ClientAuth   string `json:"clientAuth"`
+		// ClientSubjects are the certificate subjects accepted in place of AUTH_TOKEN
+		ClientSubjects string `json:"clientSubjects"`
+		RedirectPort   string `json:"redirectPort"`
+	} `json:"tls"`
+	CORSSettings struct {
+		AllowedOrigins   string `json:"allowedOrigins"`
+		AllowedMethods   string `json:"allowedMethods"`
+		AllowedHeaders   string `json:"allowedHeaders"`
+		ExposedHeaders   string `json:"exposedHeaders"`
+		AllowCredentials bool   `json:"allowCredentials"`
+		MaxAge           int    `json:"maxAge"`
+	} `json:"cors"`
+	IPFilterSettings struct {
+		TrustedProxies  string `json:"trustedProxies"`
+		ClientIPHeaders string `json:"clientIpHeaders"`
+		Allow           string `json:"allow"`
+		Deny            string `json:"deny"`
+		RouteAllow      string `json:"routeAllow"`
+		RouteDeny       string `json:"routeDeny"`
+	} `json:"ipFilter"`
+	TracingSettings struct {
+		Exporter    string  `json:"exporter"`
+		Endpoint    string  `json:"endpoint"`
+		SampleRatio float64 `json:"sampleRatio"`
+	} `json:"tracing"`
+	FileMakerSettings struct {
+		Host           string `json:"host"`
+		Database       string `json:"database"`
+		Username       string `json:"username"`
+		Password       string `json:"password"`
+		ScriptName     string `json:"scriptName"`
+		ScriptSchedule string `json:"scriptSchedule"`
+	} `json:"filemaker"`
 }
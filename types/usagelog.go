@@ -2,6 +2,8 @@ package types
 
 import (
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 type UsageLog struct {
@@ -14,6 +16,21 @@ type UsageLog struct {
 	CreatedAt time.Time `json:"created_at"`
 	Response  RawJSON   `json:"response"`
 	Request   RawJSON   `json:"request"`
+	// ClientIP is the client address, resolved behind trusted proxies
+	ClientIP string `json:"client_ip,omitempty"`
+	// ClientIdentity is the subject of a verified client certificate
+	ClientIdentity string `json:"client_identity,omitempty"`
+	// TimeoutMs is the timeout the route ran with, zero when it had none
+	TimeoutMs int64 `json:"timeout_ms,omitempty"`
+	TimedOut  bool  `json:"timed_out,omitempty"`
+	// RequestID correlates the log with the X-Request-ID echoed to the client
+	RequestID string `json:"request_id,omitempty"`
+	// TraceID is the OpenTelemetry trace the request was part of
+	TraceID string `json:"trace_id,omitempty"`
+	// Stack is the stack of a panic recovered while handling the request
+	Stack string `json:"stack,omitempty"`
+	// SpanContext is the span of the request, the write of the log is traced as its child
+	SpanContext trace.SpanContext `json:"-"`
 }
 
 type RawJSON string
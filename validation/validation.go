@@ -0,0 +1,250 @@
+// Package validation checks request bodies and parameters against the rules
+// a route declares, and reports every failing field at once.
+//
+// Rules use the go-playground/validator syntax under the binding tag, the
+// same tag gin's ShouldBind checks, e.g. `binding:"required,max=100"`.
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Where a field came from
+const (
+	InBody  = "body"
+	InPath  = "path"
+	InQuery = "query"
+)
+
+// Type is the type a parameter value must parse as
+type Type string
+
+const (
+	String  Type = "string"
+	Integer Type = "integer"
+	Number  Type = "number"
+	Boolean Type = "boolean"
+	// Date is a calendar date like 2024-01-31
+	Date Type = "date"
+)
+
+// Param declares a path or query parameter
+type Param struct {
+	Name string
+	// In is InPath or InQuery
+	In string
+	// Type defaults to String
+	Type Type
+	// Rules are validator rules checked on the parsed value, e.g. "min=1,max=100".
+	// A query parameter is optional unless they include required.
+	Rules       string
+	Description string
+}
+
+// FieldError is a field that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	In      string `json:"in"`
+	Message string `json:"message"`
+}
+
+// Error lists the fields of a request that failed validation
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + " " + f.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+	// Report fields by the name clients send
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}
+
+// CheckParam parses a parameter value and checks it against the rules
+func CheckParam(p Param, value string, present bool) *FieldError {
+	fail := func(msg string) *FieldError {
+		return &FieldError{Field: p.Name, In: p.In, Message: msg}
+	}
+	if !present || value == "" {
+		if p.In == InPath || hasRule(p.Rules, "required") {
+			return fail("is required")
+		}
+		return nil
+	}
+
+	var v any
+	var err error
+	switch p.Type {
+	case Integer:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case Number:
+		v, err = strconv.ParseFloat(value, 64)
+	case Boolean:
+		v, err = strconv.ParseBool(value)
+	case Date:
+		_, err = time.Parse(time.DateOnly, value)
+		v = value
+	default:
+		v = value
+	}
+	if err != nil {
+		return fail("must be " + article(p.Type))
+	}
+	if p.Rules == "" {
+		return nil
+	}
+	if err := validate.Var(v, p.Rules); err != nil {
+		var errs validator.ValidationErrors
+		if errors.As(err, &errs) && len(errs) > 0 {
+			return fail(message(errs[0]))
+		}
+		return fail(err.Error())
+	}
+	return nil
+}
+
+// DecodeBody decodes a JSON body into target, a pointer, and checks the
+// binding rules of its fields
+func DecodeBody(data []byte, target any) []FieldError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.Is(err, io.EOF):
+			return []FieldError{{In: InBody, Message: "is required"}}
+		case errors.As(err, &typeErr) && typeErr.Field != "":
+			return []FieldError{{Field: typeErr.Field, In: InBody, Message: "must be " + jsonKind(typeErr.Type)}}
+		}
+		return []FieldError{{In: InBody, Message: "must be valid JSON: " + err.Error()}}
+	}
+	if dec.More() {
+		return []FieldError{{In: InBody, Message: "must hold a single JSON value"}}
+	}
+
+	if t := reflect.TypeOf(target); t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	err := validate.Struct(target)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		if err != nil {
+			return []FieldError{{In: InBody, Message: err.Error()}}
+		}
+		return nil
+	}
+	fields := make([]FieldError, len(errs))
+	for i, fe := range errs {
+		// Drop the struct name the namespace starts with
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		fields[i] = FieldError{Field: field, In: InBody, Message: message(fe)}
+	}
+	return fields
+}
+
+// message turns a failed rule into a sentence fragment
+func message(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min", "gte":
+		return "must be at least " + fe.Param() + unit
+	case "max", "lte":
+		return "must be at most " + fe.Param() + unit
+	case "gt":
+		return "must be more than " + fe.Param() + unit
+	case "lt":
+		return "must be less than " + fe.Param() + unit
+	case "len":
+		return "must be exactly " + fe.Param() + unit
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "email":
+		return "must be an email address"
+	case "url", "uri":
+		return "must be a URL"
+	case "uuid", "uuid4":
+		return "must be a UUID"
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), fe.Param())
+	}
+	return "must satisfy " + fe.Tag()
+}
+
+// hasRule reports whether rules, before any dive, include name
+func hasRule(rules, name string) bool {
+	for rule := range strings.SplitSeq(rules, ",") {
+		if rule == "dive" {
+			return false
+		}
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func article(t Type) string {
+	switch t {
+	case Integer:
+		return "an integer"
+	case Date:
+		return "a date like 2006-01-02"
+	}
+	return "a " + string(t)
+}
+
+// jsonKind names the JSON type of a Go type
+func jsonKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,83 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+)
+
+type item struct {
+	Name  string   `json:"name" binding:"required,max=5"`
+	Count int      `json:"count" binding:"gte=1,lte=10"`
+	Kind  string   `json:"kind,omitempty" binding:"omitempty,oneof=a b"`
+	Tags  []string `json:"tags" binding:"dive,min=2"`
+}
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []FieldError
+	}{
+		{"valid", `{"name":"abc","count":3,"tags":["xy"]}`, nil},
+		{"empty", ``, []FieldError{{In: InBody, Message: "is required"}}},
+		{"wrong type", `{"name":"abc","count":"3"}`, []FieldError{{Field: "count", In: InBody, Message: "must be an integer"}}},
+		{"trailing data", `{"name":"abc","count":3} {}`, []FieldError{{In: InBody, Message: "must hold a single JSON value"}}},
+		{"rules", `{"name":"abcdef","count":0,"kind":"c","tags":["x"]}`, []FieldError{
+			{Field: "name", In: InBody, Message: "must be at most 5 characters"},
+			{Field: "count", In: InBody, Message: "must be at least 1"},
+			{Field: "kind", In: InBody, Message: "must be one of a, b"},
+			{Field: "tags[0]", In: InBody, Message: "must be at least 2 characters"},
+		}},
+		{"missing", `{"count":1}`, []FieldError{{Field: "name", In: InBody, Message: "is required"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DecodeBody([]byte(tt.body), &item{})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	if got := DecodeBody([]byte(`{"any":"thing"}`), &map[string]any{}); got != nil {
+		t.Errorf("Expected a map body to pass, got %+v", got)
+	}
+}
+
+func TestCheckParam(t *testing.T) {
+	tests := []struct {
+		p       Param
+		value   string
+		present bool
+		want    string
+	}{
+		{Param{Name: "from", In: InPath, Type: Date}, "2024-01-31", true, ""},
+		{Param{Name: "from", In: InPath, Type: Date}, "31/01/2024", true, "must be a date like 2006-01-02"},
+		{Param{Name: "limit", In: InQuery, Type: Integer, Rules: "min=1,max=100"}, "", false, ""},
+		{Param{Name: "limit", In: InQuery, Type: Integer, Rules: "required,min=1"}, "", false, "is required"},
+		{Param{Name: "limit", In: InQuery, Type: Integer, Rules: "min=1,max=100"}, "500", true, "must be at most 100"},
+		{Param{Name: "limit", In: InQuery, Type: Integer}, "ten", true, "must be an integer"},
+		{Param{Name: "debug", In: InQuery, Type: Boolean}, "yes", true, "must be a boolean"},
+		{Param{Name: "id", In: InPath, Rules: "max=3"}, "abcd", true, "must be at most 3 characters"},
+	}
+	for _, tt := range tests {
+		fe := CheckParam(tt.p, tt.value, tt.present)
+		got := ""
+		if fe != nil {
+			got = fe.Message
+			if fe.Field != tt.p.Name || fe.In != tt.p.In {
+				t.Errorf("Expected the error on %s in %s, got %+v", tt.p.Name, tt.p.In, fe)
+			}
+		}
+		if got != tt.want {
+			t.Errorf("%s=%q: expected %q, got %q", tt.p.Name, tt.value, tt.want, got)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	err := &Error{Fields: []FieldError{{Field: "name", Message: "is required"}, {Field: "count", Message: "must be at least 1"}}}
+	if got := err.Error(); got != "invalid request: name is required; count must be at least 1" {
+		t.Errorf("Unexpected message %q", got)
+	}
+}